@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roffe/cim/pkg/cim"
+	flag "github.com/spf13/pflag"
+)
+
+// command is a sub command of the cli, run receives the arguments following
+// the command name
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"corpus":     {"corpus analyze [flags] <dir>", corpusCmd},
+		"crc":        {"crc search --offset <offset> [flags] <files...>", crcCmd},
+		"convert":    {"convert [flags] <in> <out>", convertCmd},
+		"diff":       {"diff [flags] <a> <b>", diffCmd},
+		"hexdump":    {"hexdump [flags] <file>", hexdumpCmd},
+		"info":       {"info [flags] <file>", infoCmd},
+		"inspect":    {"inspect [flags] <file>", inspectCmd},
+		"keys":       {"keys list [flags] <file> | add [flags] <id> <file> | remove [flags] <slot> <file>", keysCmd},
+		"patch":      {"patch [flags] <patch> <file>", patchCmd},
+		"repair":     {"repair [flags] <file>", repairCmd},
+		"scan":       {"scan [flags] <dirs...>", scanCmd},
+		"serve":      {"serve [flags]", serveCmd},
+		"set":        {"set [flags] <field> <value> <file>", setCmd},
+		"transplant": {"transplant [flags] <donor> <car>", transplantCmd},
+		"validate":   {"validate [flags] <files...>", validateCmd},
+		"virginize":  {"virginize [flags] <file>", virginizeCmd},
+	}
+}
+
+// Exit codes
+const (
+	exitFailure = 1 // The command failed
+	exitUsage   = 2 // Invalid flags or arguments
+	exitInvalid = 3 // A dump failed validation
+)
+
+// exitError ends the program with code, printing err if not nil. Commands
+// return it when they already reported the failure, such as validate.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf prints the usage of fs and returns an error exiting with
+// exitUsage
+func usageErrorf(fs *flag.FlagSet, format string, args ...interface{}) error {
+	fs.Usage()
+	return &exitError{exitUsage, fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code for an error returned by a command
+func exitCode(err error) int {
+	var e *exitError
+	var report *cim.ValidationReport
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &e):
+		return e.code
+	case errors.As(err, &report):
+		return exitInvalid
+	}
+	return exitFailure
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	// Errors are reported by main, the usage goes to stderr
+	fs.SetOutput(ioutil.Discard)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands[name].usage)
+		fmt.Fprint(os.Stderr, fs.FlagUsages())
+	}
+	addCommonFlags(fs)
+	return fs
+}
+
+// parseFlags parses the flags of a command and applies the common flags
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{exitUsage, err}
+	}
+	return setup()
+}
+
+// Flags every command takes
+var (
+	debugMode   = false
+	overlayFile = ""
+	layoutFiles []string
+)
+
+func addCommonFlags(fs *flag.FlagSet) {
+	fs.BoolVarP(&debugMode, "debug", "d", debugMode, "true|false")
+	fs.StringVar(&overlayFile, "overlay", overlayFile, "yaml or json file annotating the unknown regions")
+	fs.StringSliceVar(&layoutFiles, "layout", layoutFiles, "yaml or json file describing another CIM revision, repeatable")
+}
+
+// setup applies the common flags
+func setup() error {
+	if debugMode {
+		cim.Debug = true
+		gin.SetMode(gin.DebugMode)
+	}
+	for _, f := range layoutFiles {
+		if _, err := cim.LoadLayout(f); err != nil {
+			return err
+		}
+	}
+	if overlayFile != "" {
+		o, err := cim.LoadOverlay(overlayFile)
+		if err != nil {
+			return err
+		}
+		cim.SetOverlay(o)
+	}
+	return nil
+}
+
+// Formats dumps are read and written in, empty is auto detected
+var (
+	inFormat  string
+	outFormat string
+	profile   string
+)
+
+func addInFormatFlag(fs *flag.FlagSet) {
+	fs.StringVar(&inFormat, "in-format", inFormat, "raw|inverted|ihex|srec|hex|base64, detected if empty")
+}
+
+func addOutFormatFlag(fs *flag.FlagSet) {
+	fs.StringVar(&outFormat, "out-format", outFormat, "raw|inverted|ihex|srec|hex|base64, from the file extension or the input format if empty")
+}
+
+func addProfileFlag(fs *flag.FlagSet) {
+	var names []string
+	for _, p := range cim.Profiles() {
+		names = append(names, p.Name)
+	}
+	fs.StringVar(&profile, "profile", profile, "write the exact image a chip expects, "+strings.Join(names, "|"))
+}
+
+// loadFile loads filename in the --in-format
+func loadFile(filename string) (*cim.Bin, error) {
+	var f cim.Format
+	if inFormat != "" {
+		var err error
+		if f, err = cim.ParseFormat(inFormat); err != nil {
+			return nil, err
+		}
+	}
+	fw, _, err := cim.LoadFormat(filename, f)
+	return fw, err
+}
+
+// saveFormat returns the --out-format, or the format matching the extension
+// of filename, or a binary in the encoding fw was loaded in
+func saveFormat(filename string, fw *cim.Bin) (cim.Format, error) {
+	if outFormat != "" {
+		return cim.ParseFormat(outFormat)
+	}
+	if f, ok := cim.FormatForFilename(filename); ok {
+		return f, nil
+	}
+	if fw.Encoding() == cim.Inverted {
+		return cim.FormatInverted, nil
+	}
+	return cim.FormatRaw, nil
+}
+
+// saveFile writes fw to filename, see saveFormat. With a --profile the
+// image is laid out for the chip first, binary formats then write it as is.
+func saveFile(filename string, fw *cim.Bin) error {
+	f, err := saveFormat(filename, fw)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if profile == "" {
+		err = cim.Encode(&buf, fw, f)
+	} else {
+		var p *cim.Profile
+		if p, err = cim.ProfileByName(profile); err == nil {
+			err = cim.EncodeProfile(&buf, fw, f, p)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, buf.Bytes(), 0644)
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so filename is never left half written. An existing
+// file keeps its mode, perm is used for new files.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	if fi, err := os.Stat(filename); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// printChanges prints the changes made to a dump in the output format
+func printChanges(output string, changes []cim.Change) error {
+	switch strings.ToLower(output) {
+	case "json":
+		if changes == nil {
+			changes = []cim.Change{}
+		}
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/roffe/cim/pkg/cim"
+	flag "github.com/spf13/pflag"
+)
+
+func TestExitCode(t *testing.T) {
+	fw, err := cim.LoadBytes("test.bin", make([]byte, cim.Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zeroed banks fail their checksums
+	invalid := fw.Validate()
+	if invalid == nil {
+		t.Fatal("zeroed dump validated")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"help", flag.ErrHelp, 0},
+		{"wrapped help", fmt.Errorf("parse: %w", flag.ErrHelp), 0},
+		{"usage", &exitError{exitUsage, errors.New("bad flag")}, exitUsage},
+		{"already reported", &exitError{code: exitInvalid}, exitInvalid},
+		{"validation", invalid, exitInvalid},
+		{"wrapped validation", fmt.Errorf("dump.bin: %w", invalid), exitInvalid},
+		{"failure", errors.New("read failed"), exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "dump.bin")
+
+	check := func(data string, mode os.FileMode) {
+		t.Helper()
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != data {
+			t.Errorf("content = %q, want %q", b, data)
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != mode {
+			t.Errorf("mode = %v, want %v", fi.Mode().Perm(), mode)
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("%d files left in the directory, want 1", len(entries))
+		}
+	}
+
+	if err := writeFileAtomic(filename, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check("new", 0644)
+
+	if err := os.Chmod(filename, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(filename, []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check("replaced", 0600)
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "dump.bin"), []byte("x"), 0644); err == nil {
+		t.Errorf("wrote to a missing directory")
+	}
+	check("replaced", 0600)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+func convertCmd(args []string) error {
+	fs := newFlagSet("convert")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	list := fs.Bool("list", false, "list the chip profiles")
+	checksums := fs.Bool("update-checksums", false, "recalculate every checksum, after editing a JSON or YAML dump")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *list {
+		printProfiles()
+		return nil
+	}
+	if fs.NArg() != 2 {
+		return usageErrorf(fs, "convert takes an input and an output file")
+	}
+
+	fw, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if fw.Swapped() {
+		fmt.Printf("%s: byte swapped image, swapped back\n", fs.Arg(0))
+	}
+	if *checksums {
+		if err := fw.UpdateChecksums(); err != nil {
+			return err
+		}
+	}
+	return saveFile(fs.Arg(1), fw)
+}
+
+func printProfiles() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "name\torganisation\tbyte order\tinverted\tsize\tdescription")
+	for _, p := range cim.Profiles() {
+		order := "-"
+		if p.Organisation == 16 {
+			order = "big endian"
+			if p.LittleEndian {
+				order = "little endian"
+			}
+		}
+		size := "image"
+		if p.Size > 0 {
+			size = fmt.Sprint(p.Size)
+		}
+		if p.Pad != 0 {
+			size += fmt.Sprintf(", pad %02X", p.Pad)
+		}
+		fmt.Fprintf(w, "%s\tx%d\t%s\t%t\t%s\t%s\n", p.Name, p.Organisation, order, p.Inverted, size, p.Description)
+	}
+	w.Flush()
+}
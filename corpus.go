@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/roffe/cim/pkg/corpus"
+)
+
+func corpusCmd(args []string) error {
+	fs := newFlagSet("corpus")
+	output := fs.StringP("output", "o", "text", "text|json")
+	unknown := fs.Bool("unknown", false, "only report the unknown regions")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || fs.Arg(0) != "analyze" {
+		return usageErrorf(fs, "corpus takes analyze and a directory")
+	}
+
+	bins, errs, err := corpus.LoadDir(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(bins) == 0 {
+		return fmt.Errorf("no loadable dumps in %s", fs.Arg(1))
+	}
+	r, err := corpus.Analyze(bins)
+	if err != nil {
+		return err
+	}
+	for _, err := range errs {
+		r.Errors = append(r.Errors, err.Error())
+	}
+	if *unknown {
+		var groups []corpus.Group
+		for _, g := range r.Groups {
+			if strings.Contains(g.Name, "Unknown") {
+				groups = append(groups, g)
+			}
+		}
+		r.Groups = groups
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		printCorpusReport(r)
+	}
+	return nil
+}
+
+func printCorpusReport(r *corpus.Report) {
+	fmt.Printf("%d dump(s) analysed\n", r.Dumps)
+	for _, e := range r.Errors {
+		fmt.Println("skipped", e)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, g := range r.Groups {
+		fmt.Fprintf(w, "\n%s (0x%03X+%d)\n", g.Name, g.Offset, g.Length)
+		fmt.Fprintln(w, "offset\tmin\tmax\tdistinct\tentropy\tcorrelations\thint")
+		for _, b := range g.Bytes {
+			var corr []string
+			for _, name := range []string{"model_year", "partno", "key_count", "sps_count"} {
+				if c, ok := b.Correlations[name]; ok {
+					corr = append(corr, fmt.Sprintf("%s=%+.2f", name, c))
+				}
+			}
+			fmt.Fprintf(w, "0x%03X\t%02X\t%02X\t%d\t%.2f\t%s\t%s\n",
+				b.Offset, b.Min, b.Max, b.Distinct, b.Entropy, strings.Join(corr, " "), b.Hint)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/roffe/cim/pkg/crc16"
+)
+
+func crcCmd(args []string) error {
+	fs := newFlagSet("crc")
+	addInFormatFlag(fs)
+	offset := fs.String("offset", "", "offset of the stored checksum, decimal or 0x hex")
+	from := fs.String("from", "0", "start of the search window")
+	to := fs.String("to", "", "end of the search window, exclusive (default the checksum offset)")
+	minLen := fs.Int("min-len", 4, "shortest range tried")
+	top := fs.Int("top", 20, "number of results shown, 0 for all")
+	output := fs.StringP("output", "o", "text", "text|json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 || fs.Arg(0) != "search" || *offset == "" {
+		return usageErrorf(fs, "crc takes search, --offset and one or more files")
+	}
+
+	var opts crc16.SearchOptions
+	var err error
+	if opts.ChecksumOffset, err = parseOffset(*offset); err != nil {
+		return err
+	}
+	if opts.From, err = parseOffset(*from); err != nil {
+		return err
+	}
+	opts.To = opts.ChecksumOffset
+	if *to != "" {
+		if opts.To, err = parseOffset(*to); err != nil {
+			return err
+		}
+	}
+	opts.MinLength = *minLen
+
+	var dumps [][]byte
+	for _, filename := range fs.Args()[1:] {
+		fw, err := loadFile(filename)
+		if err != nil {
+			return err
+		}
+		b, err := fw.Bytes()
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, b)
+	}
+
+	ranked, err := crc16.SearchCorpus(dumps, opts)
+	if err != nil {
+		return err
+	}
+	if *top > 0 && len(ranked) > *top {
+		ranked = ranked[:*top]
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		out, err := json.MarshalIndent(ranked, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		for _, m := range ranked {
+			fmt.Printf("%d/%d %s\n", m.Hits, len(dumps), m.Match)
+		}
+	}
+	return nil
+}
+
+// parseOffset parses an offset or length given in decimal or 0x hex
+func parseOffset(s string) (int, error) {
+	n, err := strconv.ParseInt(s, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset or length %q, use decimal or 0x hex", s)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "github.com/roffe/cim/pkg/cim"
+
+func diffCmd(args []string) error {
+	fs := newFlagSet("diff")
+	addInFormatFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageErrorf(fs, "diff takes exactly two files")
+	}
+
+	a, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	changes, err := cim.Diff(a, b)
+	if err != nil {
+		return err
+	}
+
+	return printChanges(*output, changes)
+}
@@ -1,10 +1,13 @@
 module github.com/roffe/cim
 
-go 1.17
+go 1.18
 
 require github.com/albenik/bcd v0.0.0-20170831201648-635201416bc7
 
-require github.com/spf13/pflag v1.0.5
+require (
+	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v2 v2.2.8
+)
 
 require (
 	github.com/asaskevich/govalidator v0.0.0-20200907205600-7a23bdc65eef // indirect
@@ -26,7 +29,6 @@ require (
 	github.com/ugorji/go/codec v1.1.7 // indirect
 	go.mongodb.org/mongo-driver v1.7.5 // indirect
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
 
 require (
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// hexRow is a line of a hexdump
+type hexRow struct {
+	Offset int    `json:"offset"`
+	Hex    string `json:"hex"`
+	ASCII  string `json:"ascii"`
+}
+
+func hexdumpCmd(args []string) error {
+	fs := newFlagSet("hexdump")
+	addInFormatFlag(fs)
+	offset := fs.String("offset", "0", "offset of the first byte, decimal or 0x hex")
+	length := fs.String("len", "0", "number of bytes, decimal or 0x hex, 0 for the rest of the dump")
+	image := fs.Bool("image", false, "dump the image in the layout it was loaded from instead of the 9-3 CIM layout")
+	output := fs.StringP("output", "o", "text", "text|json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf(fs, "hexdump takes exactly one file")
+	}
+	off, err := parseOffset(*offset)
+	if err != nil {
+		return err
+	}
+	n, err := parseOffset(*length)
+	if err != nil {
+		return err
+	}
+
+	fw, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := fw.Bytes()
+	if *image {
+		b, err = fw.Image()
+	}
+	if err != nil {
+		return err
+	}
+	if off < 0 || off > len(b) {
+		return fmt.Errorf("offset 0x%X is outside the %d byte dump", off, len(b))
+	}
+	if n == 0 {
+		n = len(b) - off
+	}
+	if n < 0 || off+n > len(b) {
+		return fmt.Errorf("%d byte(s) at 0x%X are outside the %d byte dump", n, off, len(b))
+	}
+
+	var rows []hexRow
+	for i := off; i < off+n; i += 16 {
+		end := i + 16
+		if end > off+n {
+			end = off + n
+		}
+		var h, a strings.Builder
+		for j, c := range b[i:end] {
+			if j > 0 {
+				h.WriteByte(' ')
+			}
+			fmt.Fprintf(&h, "%02X", c)
+			if c < 0x20 || c > 0x7E {
+				c = '.'
+			}
+			a.WriteByte(c)
+		}
+		rows = append(rows, hexRow{Offset: i, Hex: h.String(), ASCII: a.String()})
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		if rows == nil {
+			rows = []hexRow{}
+		}
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		for _, r := range rows {
+			fmt.Printf("%03X  %-47s  |%s|\n", r.Offset, r.Hex, r.ASCII)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+var outputMode = "pretty"
+
+func infoCmd(args []string) error {
+	fs := newFlagSet("info")
+	addInFormatFlag(fs)
+	fs.StringVarP(&outputMode, "output", "o", outputMode, "pretty|json|yaml|string")
+	fs.StringVar(&outFormat, "out-format", outFormat, "raw|inverted|ihex|srec|hex|base64, write the dump to stdout in the format instead of printing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf(fs, "info takes exactly one file")
+	}
+	return info(fs.Arg(0))
+}
+
+// info prints the dump in the --output mode, or writes it to stdout in the
+// --out-format. A dump failing validation is printed before the problems
+// are returned.
+func info(filename string) error {
+	fw, err := loadFile(filename)
+	if err != nil {
+		return err
+	}
+	if outFormat != "" {
+		f, err := cim.ParseFormat(outFormat)
+		if err != nil {
+			return err
+		}
+		if err := fw.Validate(); err != nil {
+			return err
+		}
+		return cim.Encode(os.Stdout, fw, f)
+	}
+
+	switch strings.ToLower(outputMode) {
+	case "string":
+		fw.Dump()
+	case "json":
+		b, err := fw.Json()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b[:]))
+	case "yaml":
+		b, err := fw.Yaml()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(b))
+	case "pretty":
+		fw.Pretty()
+	default:
+		fw.Pretty()
+	}
+	return fw.Validate()
+}
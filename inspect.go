@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/roffe/cim/pkg/jesus"
+)
+
+func inspectCmd(args []string) error {
+	fs := newFlagSet("inspect")
+	addInFormatFlag(fs)
+	offset := fs.String("offset", "0", "offset of the first byte, decimal or 0x hex")
+	length := fs.String("len", "1", "number of bytes, decimal or 0x hex")
+	output := fs.StringP("output", "o", "text", "text|json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf(fs, "inspect takes exactly one file")
+	}
+	off, err := parseOffset(*offset)
+	if err != nil {
+		return err
+	}
+	n, err := parseOffset(*length)
+	if err != nil {
+		return err
+	}
+
+	fw, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	interpretations, err := jesus.InspectBin(fw, off, n)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		out, err := json.MarshalIndent(interpretations, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		for _, i := range interpretations {
+			fmt.Printf("%-18s %s\n", i.Name, i.Value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+func keysCmd(args []string) error {
+	fs := newFlagSet("keys")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the changed dump to file, dry run if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		if fs.NArg() != 2 {
+			return usageErrorf(fs, "keys list takes exactly one file")
+		}
+		return listKeys(fs.Arg(1), *output)
+	case "add":
+		if fs.NArg() != 3 {
+			return usageErrorf(fs, "keys add takes a key id and a file")
+		}
+		return applyOps(fs.Arg(2), *output, *write, cim.PatchOp{Add: "key", Value: fs.Arg(1)})
+	case "remove":
+		if fs.NArg() != 3 {
+			return usageErrorf(fs, "keys remove takes a slot and a file")
+		}
+		return applyOps(fs.Arg(2), *output, *write, cim.PatchOp{Remove: fmt.Sprintf("key[%s]", fs.Arg(1))})
+	}
+	return usageErrorf(fs, "keys takes list, add or remove")
+}
+
+// keyList is the key listing of a dump
+type keyList struct {
+	Count  uint8    `json:"count"`
+	Errors uint8    `json:"errors"`
+	Keys   []string `json:"keys"`
+}
+
+func listKeys(filename, output string) error {
+	fw, err := loadFile(filename)
+	if err != nil {
+		return err
+	}
+	l := keyList{Count: fw.Keys.Count1, Errors: fw.Keys.Errors1, Keys: []string{}}
+	for i := 0; i < int(l.Count) && i < cim.MaxKeys; i++ {
+		l.Keys = append(l.Keys, fmt.Sprintf("%X", fw.Keys.Data1[i]))
+	}
+
+	switch strings.ToLower(output) {
+	case "json":
+		b, err := json.MarshalIndent(l, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "slot\tid")
+		for i, id := range l.Keys {
+			fmt.Fprintf(w, "%d\t%s\n", i, id)
+		}
+		w.Flush()
+		fmt.Printf("%d key(s) programmed, %d error(s)\n", l.Count, l.Errors)
+	}
+	return nil
+}
@@ -1,68 +1,83 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
-	"strings"
+	"os"
+	"sort"
 
 	"github.com/gin-gonic/gin"
-	"github.com/roffe/cim/pkg/cim"
-	"github.com/roffe/cim/pkg/server"
 	flag "github.com/spf13/pflag"
 )
 
-var (
-	outputMode     = "pretty"
-	debugMode      = false
-	enableShutdown = true
-	httpPath       = ""
-)
-
 func init() {
 	gin.SetMode(gin.ReleaseMode)
 
-	flag.StringVarP(&outputMode, "output", "o", outputMode, "pretty|json|string")
-	flag.BoolVarP(&debugMode, "debug", "d", debugMode, "true|false")
+	// Without a command a file is printed as by info, or the web ui started
+	// as by serve
+	flag.StringVarP(&outputMode, "output", "o", outputMode, "pretty|json|yaml|string")
 	flag.BoolVarP(&enableShutdown, "shutdown", "s", enableShutdown, "true|false enable shutdown api")
 	flag.StringVar(&httpPath, "path", httpPath, "set http path")
-	flag.Parse()
-
-	if debugMode {
-		cim.Debug = true
-		gin.SetMode(gin.DebugMode)
-	}
+	addInFormatFlag(flag.CommandLine)
+	flag.StringVar(&outFormat, "out-format", outFormat, "raw|inverted|ihex|srec|hex|base64, write the dump to stdout in the format instead of printing it")
+	addCommonFlags(flag.CommandLine)
+	// Errors are reported by main, the usage goes to stderr
+	flag.CommandLine.SetOutput(ioutil.Discard)
+	flag.Usage = usage
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [file]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s <command> [flags] [args]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	fmt.Fprint(os.Stderr, flag.CommandLine.FlagUsages())
+}
+
 func main() {
-	// if we pass a filename, print to the console instead of starting ui
-	if len(flag.Args()) >= 1 {
-		filename := flag.Args()[0]
-		fw, err := cim.MustLoad(filename)
-		if err != nil {
-			log.Fatal(err)
+	name, err := run(os.Args[1:])
+	if code := exitCode(err); code != 0 {
+		var e *exitError
+		if !errors.As(err, &e) || e.err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", os.Args[0], name, err)
 		}
-		switch strings.ToLower(outputMode) {
-		case "string":
-			fw.Dump()
-		case "json":
-			b, err := fw.Json()
-			if err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println(string(b[:]))
-		case "pretty":
-			fw.Pretty()
-		default:
-			fw.Pretty()
+		os.Exit(code)
+	}
+}
+
+// run runs the command named by the first argument and returns its name
+func run(args []string) (string, error) {
+	// sub commands parse their own flags
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return args[0], cmd.run(args[1:])
 		}
-		return
 	}
 
-	// Run web ui
-	fmt.Println("Server started @ http://localhost:8080")
-	if err := server.Run(enableShutdown, httpPath); err != nil {
-		log.Fatal(err)
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", err
+		}
+		return "", &exitError{exitUsage, err}
+	}
+	if err := setup(); err != nil {
+		return "", err
+	}
+	// if we pass a filename, print to the console instead of starting ui
+	if flag.NArg() >= 1 {
+		return "info", info(flag.Arg(0))
 	}
+	return "serve", serve()
 }
@@ -0,0 +1,44 @@
+package main
+
+import "github.com/roffe/cim/pkg/cim"
+
+func patchCmd(args []string) error {
+	fs := newFlagSet("patch")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the patched dump to file, dry run if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageErrorf(fs, "patch takes a patch and a dump file")
+	}
+
+	p, err := cim.LoadPatch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fw, err := loadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	out, err := p.Apply(fw)
+	if err != nil {
+		return err
+	}
+	changes, err := cim.Diff(fw, out)
+	if err != nil {
+		return err
+	}
+
+	if err := printChanges(*output, changes); err != nil {
+		return err
+	}
+
+	if *write != "" {
+		return saveFile(*write, out)
+	}
+	return nil
+}
@@ -4,124 +4,194 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/albenik/bcd"
+	"github.com/ghostiam/binstruct"
 )
 
-type writeOp struct {
-	order binary.ByteOrder // Endianess
-	data  interface{}
+// fieldWriters are the encode counterparts of the custom binstruct read
+// functions referenced by name in the bin tags
+var fieldWriters = map[string]func(v reflect.Value) ([]byte, error){
+	"BCDDate": func(v reflect.Value) ([]byte, error) {
+		return bcdDateBytes("060102", v.Interface().(time.Time))
+	},
+	"BCDDateR": func(v reflect.Value) ([]byte, error) {
+		return bcdDateBytes("020106", v.Interface().(time.Time))
+	},
+	"ReadSN": func(v reflect.Value) ([]byte, error) {
+		if v.Uint() > 9999999999 {
+			return nil, fmt.Errorf("%d does not fit in 10 BCD digits", v.Uint())
+		}
+		return bcd.FromUint(v.Uint(), 5), nil
+	},
+}
+
+type encoder struct {
+	buf *bytes.Buffer
+	bin *Bin
 }
 
-// Return the byte representation of the memory dump
+// Return the byte representation of the memory dump.
+//
+// For a loaded dump that hasn't been edited the result is byte identical to
+// the loaded image, including BCD dates and serials that don't decode to a
+// valid value.
 func (bin *Bin) Bytes() ([]byte, error) {
-	ops := []writeOp{
-		{binary.BigEndian, bin.MagicByte},
-		{binary.LittleEndian, bin.programmingDate()[1:4]},
-		{binary.LittleEndian, bin.SasOption},
-		{binary.BigEndian, bin.UnknownBytes1},
-		{binary.BigEndian, bin.PartNo1},
-		{binary.BigEndian, []byte(bin.PartNo1Rev)},
-		{binary.BigEndian, bin.ConfigurationVersion},
-		{binary.BigEndian, bin.PnBase1},
-		{binary.BigEndian, []byte(bin.PnBase1Rev)},
-		{binary.BigEndian, []byte(bin.Vin.Data)},
-		{binary.BigEndian, bin.Vin.Value},
-		{binary.BigEndian, bin.Vin.Unknown},
-		{binary.LittleEndian, bin.Vin.SpsCount},
-		{binary.LittleEndian, bin.Vin.Checksum},
-		{binary.BigEndian, []byte(strings.Join(bin.ProgrammingID, ""))},
-		{binary.BigEndian, bin.UnknownData3.Data1},
-		{binary.LittleEndian, bin.UnknownData3.Checksum1},
-		{binary.BigEndian, bin.UnknownData3.Data2},
-		{binary.LittleEndian, bin.UnknownData3.Checksum2},
-		{binary.BigEndian, bin.Pin.Data1},
-		{binary.BigEndian, bin.Pin.Unknown1},
-		{binary.LittleEndian, bin.Pin.Checksum1},
-		{binary.BigEndian, bin.Pin.Data2},
-		{binary.BigEndian, bin.Pin.Unknown2},
-		{binary.LittleEndian, bin.Pin.Checksum2},
-		{binary.BigEndian, bin.UnknownData4.Data},
-		{binary.LittleEndian, bin.UnknownData4.Checksum},
-		{binary.BigEndian, bin.UnknownData1.Data1},
-		{binary.LittleEndian, bin.UnknownData1.Checksum1},
-		{binary.BigEndian, bin.UnknownData1.Data2},
-		{binary.LittleEndian, bin.UnknownData1.Checksum2},
-		{binary.BigEndian, bin.Const1.Data},
-		{binary.LittleEndian, bin.Const1.Checksum},
-		{binary.BigEndian, bin.Keys.IskHI1},
-		{binary.BigEndian, bin.Keys.IskLO1},
-		{binary.BigEndian, bin.Keys.Data1[0]},
-		{binary.BigEndian, bin.Keys.Data1[1]},
-		{binary.BigEndian, bin.Keys.Data1[2]},
-		{binary.BigEndian, bin.Keys.Data1[3]},
-		{binary.BigEndian, bin.Keys.Data1[4]},
-		{binary.LittleEndian, bin.Keys.Count1},
-		{binary.BigEndian, bin.Keys.Constant1},
-		{binary.LittleEndian, bin.Keys.Errors1},
-		{binary.LittleEndian, bin.Keys.Checksum1},
-		{binary.BigEndian, bin.Keys.IskHI2},
-		{binary.BigEndian, bin.Keys.IskLO2},
-		{binary.BigEndian, bin.Keys.Data2[0]},
-		{binary.BigEndian, bin.Keys.Data2[1]},
-		{binary.BigEndian, bin.Keys.Data2[2]},
-		{binary.BigEndian, bin.Keys.Data2[3]},
-		{binary.BigEndian, bin.Keys.Data2[4]},
-		{binary.LittleEndian, bin.Keys.Count2},
-		{binary.BigEndian, bin.Keys.Constant2},
-		{binary.LittleEndian, bin.Keys.Errors2},
-		{binary.LittleEndian, bin.Keys.Checksum2},
-		{binary.BigEndian, bin.UnknownData5.Data},
-		{binary.LittleEndian, bin.UnknownData5.Checksum},
-		{binary.BigEndian, bin.Sync.Data[0]},
-		{binary.BigEndian, bin.Sync.Data[1]},
-		{binary.BigEndian, bin.Sync.Data[2]},
-		{binary.BigEndian, bin.Sync.Data[3]},
-		{binary.BigEndian, bin.Sync.Data[4]},
-		{binary.LittleEndian, bin.Sync.Checksum},
-		{binary.BigEndian, bin.UnknownData6.Data1},
-		{binary.LittleEndian, bin.UnknownData6.Checksum1},
-		{binary.BigEndian, bin.UnknownData6.Data2},
-		{binary.LittleEndian, bin.UnknownData6.Checksum2},
-		{binary.BigEndian, bin.UnknownData7.Data1},
-		{binary.LittleEndian, bin.UnknownData7.Checksum1},
-		{binary.BigEndian, bin.UnknownData7.Data2},
-		{binary.LittleEndian, bin.UnknownData7.Checksum2},
-		{binary.BigEndian, bin.UnknownData8.Data},
-		{binary.LittleEndian, bin.UnknownData8.Checksum},
-		{binary.BigEndian, bin.UnknownData9.Data},
-		{binary.LittleEndian, bin.UnknownData9.Checksum},
-		{binary.BigEndian, bin.UnknownData2.Data1},
-		{binary.LittleEndian, bin.UnknownData2.Checksum1},
-		{binary.BigEndian, bin.UnknownData2.Data2},
-		{binary.LittleEndian, bin.UnknownData2.Checksum2},
-		{binary.BigEndian, bcd.FromUint64(bin.SnSticker)[3:8]},
-		{binary.LittleEndian, bin.programmingFactoryDate()[1:4]},
-		{binary.LittleEndian, bin.UnknownBytes2},
-		{binary.LittleEndian, bin.DelphiPN},
-		{binary.BigEndian, bin.UnknownBytes3},
-		{binary.LittleEndian, bin.PartNo},
-		{binary.LittleEndian, bin.UnknownData14},
-		{binary.LittleEndian, bin.PSK.Low},
-		{binary.LittleEndian, bin.PSK.High},
-		{binary.LittleEndian, bin.PSK.Constant},
-		{binary.LittleEndian, bin.PSK.Unknown},
-		{binary.LittleEndian, bin.PSK.Checksum},
-		{binary.BigEndian, bin.UnknownData10.Data1},
-		{binary.LittleEndian, bin.UnknownData10.Checksum1},
-		{binary.BigEndian, bin.UnknownData10.Data2},
-		{binary.LittleEndian, bin.UnknownData10.Checksum2},
-		{binary.BigEndian, bin.EOF},
+	e := &encoder{
+		buf: bytes.NewBuffer(make([]byte, 0, 512)),
+		bin: bin,
+	}
+	if err := e.encodeStruct(nil, reflect.ValueOf(bin).Elem()); err != nil {
+		return nil, fmt.Errorf("failed to write bytes to buffer: %v", err)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// rawField returns the loaded bytes of a custom read function field if the
+// field still holds the value they decode to
+func (e *encoder) rawField(v reflect.Value, tag binTag) ([]byte, bool) {
+	offset := e.buf.Len()
+	if offset+tag.length > len(e.bin.raw) {
+		return nil, false
+	}
+	raw := e.bin.raw[offset : offset+tag.length]
+	m := reflect.ValueOf(e.bin).MethodByName(tag.fn)
+	if !m.IsValid() {
+		return nil, false
 	}
-	buf := bytes.NewBuffer([]byte{})
-	for _, o := range ops {
-		if err := binary.Write(buf, o.order, o.data); err != nil {
-			return nil, fmt.Errorf("failed to write bytes to buffer: %v", err)
+	ret := m.Call([]reflect.Value{reflect.ValueOf(binstruct.NewReaderFromBytes(raw, binary.BigEndian, false))})
+	if !ret[1].IsNil() || !reflect.DeepEqual(ret[0].Interface(), v.Interface()) {
+		return nil, false
+	}
+	return raw, true
+}
+
+func (e *encoder) encodeStruct(path []string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, err := parseTag(sf.Tag.Get("bin"))
+		if err != nil {
+			return fmt.Errorf("%s: %v", sf.Name, err)
+		}
+		if tag.ignore {
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), sf.Name)
+		if err := e.encodeField(fieldPath, v.Field(i), tag); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func (e *encoder) encodeField(path []string, v reflect.Value, tag binTag) error {
+	name := strings.Join(path, ".")
+	buf := e.buf
 
-	return buf.Bytes(), nil
+	if tag.fn != "" {
+		if raw, ok := e.rawField(v, tag); ok {
+			buf.Write(raw)
+			return nil
+		}
+		w, ok := fieldWriters[tag.fn]
+		if !ok {
+			return fmt.Errorf("%s: no writer for custom read function %s", name, tag.fn)
+		}
+		b, err := w(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if len(b) != tag.length {
+			return fmt.Errorf("%s: encoded to %d bytes, expected %d", name, len(b), tag.length)
+		}
+		buf.Write(b)
+		return nil
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if tag.le {
+		order = binary.LittleEndian
+	}
+
+	switch v.Kind() {
+	case reflect.Uint8:
+		buf.WriteByte(uint8(v.Uint()))
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size := tag.length
+		if size == 0 {
+			size = int(v.Type().Size())
+		}
+		b := make([]byte, 8)
+		switch size {
+		case 2:
+			order.PutUint16(b, uint16(v.Uint()))
+		case 4:
+			order.PutUint32(b, uint32(v.Uint()))
+		case 8:
+			order.PutUint64(b, v.Uint())
+		default:
+			return fmt.Errorf("%s: unsupported integer length %d", name, size)
+		}
+		buf.Write(b[:size])
+	case reflect.String:
+		if v.Len() != tag.length {
+			return fmt.Errorf("%s: is %d bytes, expected %d", name, v.Len(), tag.length)
+		}
+		buf.WriteString(v.String())
+	case reflect.Slice:
+		if v.Len() != tag.length {
+			return fmt.Errorf("%s: has %d elements, expected %d", name, v.Len(), tag.length)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && tag.elem == nil {
+			buf.Write(v.Bytes())
+			return nil
+		}
+		elemTag := binTag{}
+		if tag.elem != nil {
+			elemTag = *tag.elem
+		}
+		for i := 0; i < v.Len(); i++ {
+			elemPath := append(append([]string{}, path[:len(path)-1]...), fmt.Sprintf("%s[%d]", path[len(path)-1], i))
+			if err := e.encodeField(elemPath, v.Index(i), elemTag); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		start := buf.Len()
+		if err := e.encodeStruct(path, v); err != nil {
+			return err
+		}
+		if tag.length != 0 && buf.Len()-start != tag.length {
+			return fmt.Errorf("%s: encoded to %d bytes, expected %d", name, buf.Len()-start, tag.length)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %s", name, v.Type())
+	}
+	return nil
+}
+
+// setBytes replaces the contents of bin with the decoded image b. The image
+// is kept so fields that can't represent every byte value (BCD dates &
+// serial) are written back verbatim unless edited
+func (bin *Bin) setBytes(b []byte) error {
+	fw := Bin{
+		filename: bin.filename,
+		encoding: bin.encoding,
+		layout:   bin.layout,
+		image:    bin.image,
+		format:   bin.format,
+		swapped:  bin.swapped,
+	}
+	if err := binstruct.UnmarshalBE(b, &fw); err != nil {
+		return err
+	}
+	fw.raw = append([]byte(nil), b...)
+	*bin = fw
+	return nil
 }
 
 // Return Bytes() Xored for you ready for flashing
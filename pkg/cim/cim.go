@@ -5,10 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/crc32"
-	"io/ioutil"
-	"log"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/albenik/bcd"
@@ -21,33 +17,60 @@ var (
 
 const IsoDate = "2006-01-02"
 
-// Load a file from disk
+// Load a file from disk in any registered format, see Decode
 func Load(filename string) (*Bin, error) {
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	return LoadBytes(filename, b)
+	fw, _, err := LoadFormat(filename, "")
+	return fw, err
 }
 
-// Load a byte slice as a named binary
+// Load a byte slice as a named binary. b must match one of the registered
+// layouts as is and may be plain or inverted, the detected layout and
+// encoding are available from Layout() and Encoding(). b is not modified.
+// Load and Decode also undo byte swapped and padded images.
 func LoadBytes(filename string, b []byte) (*Bin, error) {
-	fw := Bin{
+	return loadBytes(filename, []variant{{data: b}}, []Encoding{Plain, Inverted})
+}
+
+// loadBytes loads the most plausible variant of an image in the most
+// plausible of the given encodings
+func loadBytes(filename string, variants []variant, encs []Encoding) (*Bin, error) {
+	data := make([][]byte, len(variants))
+	for i, v := range variants {
+		data[i] = v.data
+	}
+	layout, enc, v, err := detectLayout(data, encs)
+	if err != nil {
+		return nil, withFilename(filename, err)
+	}
+
+	fw := &Bin{
 		filename: filename,
+		encoding: enc,
+		layout:   layout,
+		swapped:  variants[v].swapped,
 	}
 
-	//xor bytes if magicByte is not 0x20
-	if b[0] != 0x20 {
-		for i, bb := range b {
-			b[i] = bb ^ 0xFF
+	image := append([]byte(nil), data[v]...)
+	if enc == Inverted {
+		for i, bb := range image {
+			image[i] = bb ^ 0xFF
 		}
 	}
+	fw.image = image
 
 	// Unpack bytes into struct
-	if err := binstruct.UnmarshalBE(b, &fw); err != nil {
+	if err := fw.setBytes(layout.toCanonical(image)); err != nil {
 		return nil, err
 	}
-	return &fw, nil
+	return fw, nil
+}
+
+// withFilename prefixes err with filename, if there is one
+func withFilename(filename string, err error) error {
+	if filename == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", filename, err)
 }
 
 func MustLoad(filename string) (*Bin, error) {
@@ -76,6 +99,12 @@ func MustLoadBytes(filename string, b []byte) (*Bin, error) {
 // Cim eeprom layout
 type Bin struct {
 	filename               string        `bin:"-" json:"-"`
+	raw                    []byte        `bin:"-" json:"-"` // decoded image, see Bytes()
+	image                  []byte        `bin:"-" json:"-"` // plain image as loaded, see Image()
+	encoding               Encoding      `bin:"-" json:"-"`
+	layout                 *Layout       `bin:"-" json:"-"`
+	format                 Format        `bin:"-" json:"-"`
+	swapped                bool          `bin:"-" json:"-"`
 	MagicByte              byte          `bin:"len:1" json:"magic_byte"`               // 0x20
 	ProgrammingDate        time.Time     `bin:"BCDDate,len:3" json:"programming_date"` // BCD Binary-Coded Decimal yy-mm-dd
 	SasOption              uint8         `bin:"len:1" json:"sas_option"`               // Steering Angle Sensor 0x03 = true
@@ -112,53 +141,67 @@ type Bin struct {
 	EOF                    byte          `bin:"len:1" json:"eof"`
 }
 
-func (bin *Bin) programmingDate() []byte {
-	return pDateUint32BCD("060102", bin.ProgrammingDate)
-}
-
-func (bin *Bin) programmingFactoryDate() []byte {
-	return pDateUint32BCD("020106", bin.ProgrammingFactoryDate)
+// checkBCDDate reports if date can be stored as a two digit year BCD date
+func checkBCDDate(date time.Time) error {
+	if date.Year() < 1969 || date.Year() > 2068 {
+		return fmt.Errorf("date %s out of range for a two digit year", date.Format(IsoDate))
+	}
+	return nil
 }
 
-func pDateUint32BCD(format string, date time.Time) []byte {
+// bcdDateBytes encodes date as 3 byte BCD in the given yy/mm/dd order
+func bcdDateBytes(format string, date time.Time) ([]byte, error) {
+	if err := checkBCDDate(date); err != nil {
+		return nil, err
+	}
 	d := date.Format(format)
-	d = strings.TrimLeft(d, "0")
-	p, err := strconv.ParseUint(d, 0, 32)
-	if err != nil {
-		log.Fatal(err)
+	out := make([]byte, 3)
+	for i := range out {
+		out[i] = (d[i*2]-'0')<<4 | (d[i*2+1] - '0')
 	}
-	return bcd.FromUint32(uint32(p))
+	return out, nil
 }
 
+// Json returns the dump as indented JSON, with the decoded fields of the
+// active overlay under "overlay" and the layout name under "layout" unless
+// it is the DefaultLayout, along with the plain image as hex under "image"
+// for the bytes the fields don't cover. Fields whose value can't reproduce
+// their bytes, such as invalid BCD dates, are kept as hex under "raw", see
+// FromJSON.
 func (bin *Bin) Json() ([]byte, error) {
-	return json.MarshalIndent(bin, "", "  ")
+	values, err := bin.OverlayValues()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := bin.rawFields()
+	if err != nil {
+		return nil, err
+	}
+	var layout, image string
+	if l := bin.Layout(); l != DefaultLayout {
+		b, err := bin.Image()
+		if err != nil {
+			return nil, err
+		}
+		layout, image = l.Name, fmt.Sprintf("%X", b)
+	}
+	if values == nil && layout == "" && raw == nil {
+		return json.MarshalIndent(bin, "", "  ")
+	}
+	return json.MarshalIndent(struct {
+		*Bin
+		Layout  string            `json:"layout,omitempty"`
+		Image   string            `json:"image,omitempty"`
+		Overlay []OverlayValue    `json:"overlay,omitempty"`
+		Raw     map[string]string `json:"raw,omitempty"`
+	}{bin, layout, image, values, raw}, "", "  ")
 }
 
-// Validate all checksums and known tests to ensure a healthy bin
+// Validate all checksums and known tests to ensure a healthy bin. The
+// returned error is a *ValidationReport listing every problem found
 func (bin *Bin) Validate() error {
-	tests := []func() error{
-		bin.Vin.validate,
-		bin.Pin.validate,
-		bin.Keys.validate,
-		bin.Const1.validate,
-		bin.UnknownData1.validate,
-		bin.UnknownData2.validate,
-		bin.UnknownData3.validate,
-		bin.UnknownData4.validate,
-		bin.UnknownData5.validate,
-		bin.UnknownData6.validate,
-		bin.UnknownData7.validate,
-		bin.UnknownData8.validate,
-		bin.UnknownData9.validate,
-		bin.UnknownData10.validate,
-		bin.PSK.validate,
-		bin.Sync.validate,
-	}
-
-	for _, v := range tests {
-		if err := v(); err != nil {
-			return err
-		}
+	if r := bin.Report(); !r.OK() {
+		return r
 	}
 	return nil
 }
@@ -180,6 +223,9 @@ func (bin *Bin) SetConfVer(ver uint32) {
 }
 
 func (bin *Bin) SetProgrammingID(no int, value string) error {
+	if no < 0 || no >= len(bin.ProgrammingID) {
+		return fmt.Errorf("invalid programming id position %d", no)
+	}
 	if len(value) > 10 {
 		return fmt.Errorf("programming id to long")
 	}
@@ -187,6 +233,86 @@ func (bin *Bin) SetProgrammingID(no int, value string) error {
 	return nil
 }
 
+// SetProgrammingDate sets the date of the last SPS programming
+func (bin *Bin) SetProgrammingDate(date time.Time) error {
+	if err := checkBCDDate(date); err != nil {
+		return fmt.Errorf("programming date: %v", err)
+	}
+	bin.ProgrammingDate = truncateDate(date)
+	return nil
+}
+
+// SetFactoryDate sets the factory programming date
+func (bin *Bin) SetFactoryDate(date time.Time) error {
+	if err := checkBCDDate(date); err != nil {
+		return fmt.Errorf("factory date: %v", err)
+	}
+	bin.ProgrammingFactoryDate = truncateDate(date)
+	return nil
+}
+
+// truncateDate drops the time of day, the way a date reads back from the dump
+func truncateDate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// SetSnSticker sets the serial sticker number, stored as 10 BCD digits
+func (bin *Bin) SetSnSticker(sn uint64) error {
+	if sn > 9999999999 {
+		return fmt.Errorf("serial sticker %d does not fit in 10 digits", sn)
+	}
+	bin.SnSticker = sn
+	return nil
+}
+
+func (bin *Bin) SetPartNo1(pn uint32) {
+	bin.PartNo1 = pn
+}
+
+func (bin *Bin) SetPartNo1Rev(rev string) error {
+	r, err := revision(rev)
+	if err != nil {
+		return fmt.Errorf("partno1 revision: %v", err)
+	}
+	bin.PartNo1Rev = r
+	return nil
+}
+
+func (bin *Bin) SetPnBase1(pn uint32) {
+	bin.PnBase1 = pn
+}
+
+func (bin *Bin) SetPnBase1Rev(rev string) error {
+	r, err := revision(rev)
+	if err != nil {
+		return fmt.Errorf("pnbase1 revision: %v", err)
+	}
+	bin.PnBase1Rev = r
+	return nil
+}
+
+func (bin *Bin) SetDelphiPN(pn uint32) {
+	bin.DelphiPN = pn
+}
+
+func (bin *Bin) SetPartNo(pn uint32) {
+	bin.PartNo = pn
+}
+
+// revision validates a part number revision, 1-2 ASCII letters or digits
+// padded with a space
+func revision(rev string) (string, error) {
+	if len(rev) == 0 || len(rev) > 2 {
+		return "", fmt.Errorf("%q must be 1 or 2 characters", rev)
+	}
+	for _, c := range rev {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return "", fmt.Errorf("%q may only contain letters and digits", rev)
+		}
+	}
+	return fmt.Sprintf("%-2s", rev), nil
+}
+
 func (*Bin) BCDDate(r binstruct.Reader) (time.Time, error) {
 	return bcdDate("06-01-02", r)
 }
@@ -195,22 +321,29 @@ func (*Bin) BCDDateR(r binstruct.Reader) (time.Time, error) {
 	return bcdDate("02-01-06", r)
 }
 
+// bcdDate reads a 3 byte BCD date. Bytes that don't form a valid date,
+// such as an erased 0xFFFFFF, read as the zero time instead of failing
+// the whole load
 func bcdDate(format string, r binstruct.Reader) (time.Time, error) {
 	_, b, err := r.ReadBytes(3)
 	if err != nil {
 		return time.Time{}, err
 	}
-	return time.Parse(
+	t, err := time.Parse(
 		format,
 		fmt.Sprintf("%02X-%02X-%02X", b[0], b[1:2], b[2:3]), // dd-mm-yy
 	)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return t, nil
 }
 
 // Return Serial sticker as uint64, stored as 5byte Binary-Coded Decimal (BCD)
 func (*Bin) ReadSN(r binstruct.Reader) (uint64, error) {
 	_, b, err := r.ReadBytes(5)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	return bcd.ToUint64(b), nil
 }
@@ -219,8 +352,27 @@ func (bin *Bin) Filename() string {
 	return bin.filename
 }
 
+// Encoding returns how the dump was stored when it was loaded
+func (bin *Bin) Encoding() Encoding {
+	return bin.encoding
+}
+
+// EncodedBytes returns the dump in its layout and the given encoding
+func (bin *Bin) EncodedBytes(enc Encoding) ([]byte, error) {
+	b, err := bin.Image()
+	if err != nil {
+		return nil, err
+	}
+	if enc == Inverted {
+		for i, bb := range b {
+			b[i] = bb ^ 0xFF
+		}
+	}
+	return b, nil
+}
+
 func (bin *Bin) MD5() string {
-	b, err := bin.Bytes()
+	b, err := bin.Image()
 	if err != nil {
 		panic(err)
 	}
@@ -228,14 +380,19 @@ func (bin *Bin) MD5() string {
 }
 
 func (bin *Bin) CRC32() string {
-	b, err := bin.Bytes()
+	b, err := bin.Image()
 	if err != nil {
 		panic(err)
 	}
 	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(b))
 }
 
-// Return model year from VIN
-func (bin *Bin) ModelYear() string {
-	return fmt.Sprintf("%02s", bin.Vin.Data[9:10])
+// ModelYear returns the four digit model year decoded from the VIN, or 0 if
+// the VIN doesn't decode
+func (bin *Bin) ModelYear() int {
+	v, err := bin.Vin.Decode()
+	if err != nil {
+		return 0
+	}
+	return v.ModelYear
 }
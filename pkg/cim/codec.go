@@ -0,0 +1,569 @@
+package cim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format is a file format dumps are imported from and exported to
+type Format string
+
+const (
+	FormatRaw      Format = "raw"      // Plain binary image
+	FormatInverted Format = "inverted" // Binary image with every byte XORed with 0xFF
+	FormatIHex     Format = "ihex"     // Intel HEX
+	FormatSREC     Format = "srec"     // Motorola S-record
+	FormatHexText  Format = "hex"      // Hex digits, whitespace is ignored
+	FormatBase64   Format = "base64"   // Standard base64, whitespace is ignored
+	FormatJSON     Format = "json"     // The fields as written by Json, see FromJSON
+	FormatYAML     Format = "yaml"     // The fields as written by Yaml, see FromYAML
+)
+
+// maxImageSize bounds the address space of the addressed formats so a
+// corrupt record can't allocate gigabytes
+const maxImageSize = 1 << 20
+
+// Codec converts between a format and the image bytes. Text formats carry
+// the image in the encoding it was read in, plain or inverted, which is
+// detected on load like for binaries.
+type Codec struct {
+	Format     Format
+	Extensions []string            // Lower case file extensions, the first is used when saving
+	Detect     func(b []byte) bool // Reports if b looks like this format, nil never auto detects
+	Decode     func(b []byte) ([]byte, error)
+	Encode     func(w io.Writer, b []byte) error
+}
+
+// fixedEncodings are the formats whose image has a fixed encoding
+var fixedEncodings = map[Format]Encoding{
+	FormatRaw:      Plain,
+	FormatInverted: Inverted,
+	FormatJSON:     Plain,
+	FormatYAML:     Plain,
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = []Codec{
+		{FormatRaw, nil, nil, decodeBinary, encodeBinary},
+		{FormatInverted, nil, nil, decodeBinary, encodeBinary},
+		{FormatIHex, []string{".hex", ".ihex", ".ihx"}, detectIHex, decodeIHex, encodeIHex},
+		{FormatSREC, []string{".s19", ".srec", ".s28", ".s37", ".mot"}, detectSREC, decodeSREC, encodeSREC},
+		{FormatJSON, []string{".json"}, detectJSON, decodeJSON, encodeJSON},
+		{FormatYAML, []string{".yaml", ".yml"}, detectYAML, decodeYAML, encodeYAML},
+		{FormatHexText, []string{".txt"}, detectHexText, decodeHexText, encodeHexText},
+		{FormatBase64, []string{".b64"}, detectBase64, decodeBase64, encodeBase64},
+	}
+)
+
+// RegisterCodec adds a codec, auto detection tries codecs in registration
+// order
+func RegisterCodec(c Codec) error {
+	if c.Format == "" || c.Decode == nil || c.Encode == nil {
+		return fmt.Errorf("codec %q needs a format, Decode and Encode", c.Format)
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	for _, other := range codecs {
+		if other.Format == c.Format {
+			return fmt.Errorf("codec %s is already registered", c.Format)
+		}
+	}
+	codecs = append(codecs, c)
+	return nil
+}
+
+// Codecs returns the registered codecs in registration order
+func Codecs() []Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return append([]Codec(nil), codecs...)
+}
+
+// CodecByFormat returns the registered codec of f
+func CodecByFormat(f Format) (Codec, bool) {
+	for _, c := range Codecs() {
+		if c.Format == f {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// ParseFormat returns the registered format named s, case insensitive
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(s))
+	if _, ok := CodecByFormat(f); !ok {
+		var names []string
+		for _, c := range Codecs() {
+			names = append(names, string(c.Format))
+		}
+		return "", fmt.Errorf("unknown format %q, use %s", s, strings.Join(names, ", "))
+	}
+	return f, nil
+}
+
+// FormatForFilename returns the format whose extensions include the one of
+// filename
+func FormatForFilename(filename string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, c := range Codecs() {
+		for _, e := range c.Extensions {
+			if e == ext {
+				return c.Format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Decode reads a dump in any registered format. Text formats are detected
+// by their content, anything else is loaded as a raw or inverted binary.
+func Decode(r io.Reader) (*Bin, Format, error) {
+	return DecodeFormat(r, "")
+}
+
+// DecodeFormat reads a dump in format f, an empty format is auto detected
+func DecodeFormat(r io.Reader, f Format) (*Bin, Format, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeBytes("", b, f)
+}
+
+// LoadFormat loads a file in format f, an empty format is auto detected
+func LoadFormat(filename string, f Format) (*Bin, Format, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeBytes(filename, b, f)
+}
+
+func decodeBytes(filename string, b []byte, f Format) (*Bin, Format, error) {
+	if f == "" {
+		for _, c := range Codecs() {
+			if c.Detect != nil && c.Detect(b) {
+				f = c.Format
+				break
+			}
+		}
+	}
+
+	var encs []Encoding
+	if f == "" {
+		encs = []Encoding{Plain, Inverted}
+	} else {
+		c, ok := CodecByFormat(f)
+		if !ok {
+			return nil, "", withFilename(filename, fmt.Errorf("unknown format %q", f))
+		}
+		data, err := c.Decode(b)
+		if err != nil {
+			return nil, "", withFilename(filename, fmt.Errorf("%s: %v", f, err))
+		}
+		b = data
+		if enc, ok := fixedEncodings[f]; ok {
+			encs = []Encoding{enc}
+		} else {
+			encs = []Encoding{Plain, Inverted}
+		}
+	}
+
+	fw, err := loadBytes(filename, imageVariants(b), encs)
+	if err != nil {
+		return nil, "", err
+	}
+	if f == "" {
+		f = FormatRaw
+		if fw.encoding == Inverted {
+			f = FormatInverted
+		}
+	}
+	fw.format = f
+	return fw, f, nil
+}
+
+// Format returns the format the dump was loaded from
+func (bin *Bin) Format() Format {
+	switch {
+	case bin.format != "":
+		return bin.format
+	case bin.encoding == Inverted:
+		return FormatInverted
+	}
+	return FormatRaw
+}
+
+// Encode writes bin in format f. Raw, inverted, JSON and YAML have a fixed
+// encoding, the other text formats carry the image in the encoding it was
+// loaded in.
+func Encode(w io.Writer, bin *Bin, f Format) error {
+	c, ok := CodecByFormat(f)
+	if !ok {
+		return fmt.Errorf("unknown format %q", f)
+	}
+	enc, ok := fixedEncodings[f]
+	if !ok {
+		enc = bin.Encoding()
+	}
+	b, err := bin.EncodedBytes(enc)
+	if err != nil {
+		return err
+	}
+	return c.Encode(w, b)
+}
+
+func decodeBinary(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+func encodeBinary(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+// isText reports if b only holds printable ASCII and line breaks
+func isText(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 || c > 0x7E) && c != '\r' && c != '\n' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// firstLine returns the first non blank line of b
+func firstLine(b []byte) string {
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+// recordImage assembles addressed records, gaps are 0xFF like erased eeprom
+type recordImage struct {
+	data   []byte
+	filled int
+}
+
+func (im *recordImage) write(addr int, data []byte) error {
+	end := addr + len(data)
+	if end > maxImageSize {
+		return fmt.Errorf("address 0x%X is beyond %d bytes", end-1, maxImageSize)
+	}
+	for len(im.data) < end {
+		im.data = append(im.data, 0xFF)
+	}
+	copy(im.data[addr:], data)
+	im.filled += len(data)
+	return nil
+}
+
+func (im *recordImage) bytes() ([]byte, error) {
+	if im.filled == 0 {
+		return nil, fmt.Errorf("no data records")
+	}
+	return im.data, nil
+}
+
+// records splits b into trimmed non blank lines with their line numbers
+func records(b []byte) ([]string, []int) {
+	var lines []string
+	var numbers []int
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		if l := strings.TrimSpace(s.Text()); l != "" {
+			lines = append(lines, l)
+			numbers = append(numbers, n)
+		}
+	}
+	return lines, numbers
+}
+
+func detectIHex(b []byte) bool {
+	return isText(b) && strings.HasPrefix(firstLine(b), ":")
+}
+
+func decodeIHex(b []byte) ([]byte, error) {
+	var im recordImage
+	var base int
+	lines, numbers := records(b)
+	for i, l := range lines {
+		if !strings.HasPrefix(l, ":") {
+			return nil, fmt.Errorf("line %d: missing start code", numbers[i])
+		}
+		rec, err := hex.DecodeString(l[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+		}
+		if len(rec) < 5 || len(rec) != int(rec[0])+5 {
+			return nil, fmt.Errorf("line %d: invalid record length", numbers[i])
+		}
+		var sum byte
+		for _, c := range rec {
+			sum += c
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("line %d: checksum mismatch", numbers[i])
+		}
+		addr := int(rec[1])<<8 | int(rec[2])
+		data := rec[4 : len(rec)-1]
+		switch rec[3] {
+		case 0x00:
+			if err := im.write(base+addr, data); err != nil {
+				return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+			}
+		case 0x01:
+			return im.bytes()
+		case 0x02, 0x04:
+			if len(data) != 2 {
+				return nil, fmt.Errorf("line %d: invalid extended address", numbers[i])
+			}
+			base = int(data[0])<<8 | int(data[1])
+			if rec[3] == 0x02 {
+				base <<= 4
+			} else {
+				base <<= 16
+			}
+		case 0x03, 0x05:
+			// Start address, meaningless for an eeprom
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type %02X", numbers[i], rec[3])
+		}
+	}
+	return nil, fmt.Errorf("missing end of file record")
+}
+
+func ihexRecord(w io.Writer, typ byte, addr int, data []byte) error {
+	rec := append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), typ}, data...)
+	var sum byte
+	for _, c := range rec {
+		sum += c
+	}
+	_, err := fmt.Fprintf(w, ":%X%02X\n", rec, -sum)
+	return err
+}
+
+func encodeIHex(w io.Writer, b []byte) error {
+	for off := 0; off < len(b); off += 16 {
+		if off&0xFFFF == 0 && off > 0 {
+			if err := ihexRecord(w, 0x04, 0, []byte{byte(off >> 24), byte(off >> 16)}); err != nil {
+				return err
+			}
+		}
+		end := off + 16
+		if end > len(b) {
+			end = len(b)
+		}
+		if err := ihexRecord(w, 0x00, off&0xFFFF, b[off:end]); err != nil {
+			return err
+		}
+	}
+	return ihexRecord(w, 0x01, 0, nil)
+}
+
+func detectSREC(b []byte) bool {
+	l := firstLine(b)
+	return isText(b) && len(l) > 2 && l[0] == 'S' && l[1] >= '0' && l[1] <= '9'
+}
+
+// srecAddressLengths are the address lengths of the S-record types
+var srecAddressLengths = map[byte]int{
+	'0': 2, '1': 2, '2': 3, '3': 4, '5': 2, '6': 3, '7': 4, '8': 3, '9': 2,
+}
+
+func decodeSREC(b []byte) ([]byte, error) {
+	var im recordImage
+	lines, numbers := records(b)
+	for i, l := range lines {
+		if len(l) < 2 || l[0] != 'S' {
+			return nil, fmt.Errorf("line %d: missing start code", numbers[i])
+		}
+		alen, ok := srecAddressLengths[l[1]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown record type S%c", numbers[i], l[1])
+		}
+		rec, err := hex.DecodeString(l[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+		}
+		if len(rec) < alen+2 || len(rec) != int(rec[0])+1 {
+			return nil, fmt.Errorf("line %d: invalid record length", numbers[i])
+		}
+		var sum byte
+		for _, c := range rec {
+			sum += c
+		}
+		if sum != 0xFF {
+			return nil, fmt.Errorf("line %d: checksum mismatch", numbers[i])
+		}
+		var addr int
+		for _, c := range rec[1 : 1+alen] {
+			addr = addr<<8 | int(c)
+		}
+		switch l[1] {
+		case '1', '2', '3':
+			if err := im.write(addr, rec[1+alen:len(rec)-1]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+			}
+		case '7', '8', '9':
+			return im.bytes()
+		}
+	}
+	// The termination record is optional in practice
+	return im.bytes()
+}
+
+func srecRecord(w io.Writer, typ byte, addr int, data []byte) error {
+	rec := append([]byte{byte(len(data) + 3), byte(addr >> 8), byte(addr)}, data...)
+	var sum byte
+	for _, c := range rec {
+		sum += c
+	}
+	_, err := fmt.Fprintf(w, "S%c%X%02X\n", typ, rec, ^sum)
+	return err
+}
+
+func encodeSREC(w io.Writer, b []byte) error {
+	if len(b) > 0x10000 {
+		return fmt.Errorf("%d bytes don't fit 16 bit S1 records", len(b))
+	}
+	if err := srecRecord(w, '0', 0, []byte("CIM")); err != nil {
+		return err
+	}
+	var count int
+	for off := 0; off < len(b); off += 16 {
+		end := off + 16
+		if end > len(b) {
+			end = len(b)
+		}
+		if err := srecRecord(w, '1', off, b[off:end]); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := srecRecord(w, '5', count, nil); err != nil {
+		return err
+	}
+	return srecRecord(w, '9', 0, nil)
+}
+
+func detectHexText(b []byte) bool {
+	var digits int
+	for _, c := range b {
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+			digits++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && digits%2 == 0
+}
+
+func decodeHexText(b []byte) ([]byte, error) {
+	return hex.DecodeString(strings.Join(strings.Fields(string(b)), ""))
+}
+
+func encodeHexText(w io.Writer, b []byte) error {
+	for off := 0; off < len(b); off += 16 {
+		end := off + 16
+		if end > len(b) {
+			end = len(b)
+		}
+		row := make([]string, end-off)
+		for i, c := range b[off:end] {
+			row[i] = fmt.Sprintf("%02X", c)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func detectBase64(b []byte) bool {
+	if !isText(b) || len(bytes.TrimSpace(b)) == 0 {
+		return false
+	}
+	_, err := decodeBase64(b)
+	return err == nil
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(b)), ""))
+}
+
+func encodeBase64(w io.Writer, b []byte) error {
+	_, err := fmt.Fprintln(w, base64.StdEncoding.EncodeToString(b))
+	return err
+}
+
+func detectJSON(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) && json.Valid(b)
+}
+
+func decodeJSON(b []byte) ([]byte, error) {
+	fw, err := FromJSON(b, ImportOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fw.Image()
+}
+
+// fromImage loads a plain image for the field formats
+func fromImage(b []byte) (*Bin, error) {
+	return loadBytes("", []variant{{data: b}}, []Encoding{Plain})
+}
+
+func encodeJSON(w io.Writer, b []byte) error {
+	fw, err := fromImage(b)
+	if err != nil {
+		return err
+	}
+	j, err := fw.Json()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(j))
+	return err
+}
+
+func detectYAML(b []byte) bool {
+	return strings.HasPrefix(firstLine(b), "magic_byte:")
+}
+
+func decodeYAML(b []byte) ([]byte, error) {
+	fw, err := FromYAML(b, ImportOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fw.Image()
+}
+
+func encodeYAML(w io.Writer, b []byte) error {
+	fw, err := fromImage(b)
+	if err != nil {
+		return err
+	}
+	y, err := fw.Yaml()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(y)
+	return err
+}
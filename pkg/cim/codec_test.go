@@ -0,0 +1,125 @@
+package cim
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// fill returns n bytes of 0xFF followed by data, the way gaps are filled
+func fill(n int, data ...byte) []byte {
+	return append(bytes.Repeat([]byte{0xFF}, n), data...)
+}
+
+type decodeTest struct {
+	name string
+	in   string
+	want []byte
+	err  string // substring of the expected error
+}
+
+func runDecodeTests(t *testing.T, decode func([]byte) ([]byte, error), tests []decodeTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decode([]byte(tt.in))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %X, want %X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeIHex(t *testing.T) {
+	runDecodeTests(t, decodeIHex, []decodeTest{
+		{name: "data", in: ":0400000001020304F2\n:00000001FF\n", want: []byte{1, 2, 3, 4}},
+		{name: "crlf and blank lines", in: "\r\n:0400000001020304F2\r\n\r\n:00000001FF\r\n", want: []byte{1, 2, 3, 4}},
+		{name: "gap", in: ":01000000AA55\n:01000400BB40\n:00000001FF\n", want: []byte{0xAA, 0xFF, 0xFF, 0xFF, 0xBB}},
+		{name: "out of order", in: ":01000400BB40\n:01000000AA55\n:00000001FF\n", want: []byte{0xAA, 0xFF, 0xFF, 0xFF, 0xBB}},
+		{name: "extended segment address", in: ":020000020001FB\n:01000000CC33\n:00000001FF\n", want: fill(0x10, 0xCC)},
+		{name: "extended linear address", in: ":020000040001F9\n:01000000CC33\n:00000001FF\n", want: fill(0x10000, 0xCC)},
+		{name: "start addresses ignored", in: ":0400000300001234B3\n:0400000500001234B1\n:01000000AA55\n:00000001FF\n", want: []byte{0xAA}},
+		{name: "records after eof ignored", in: ":01000000AA55\n:00000001FF\n:01000400BB40\n", want: []byte{0xAA}},
+		{name: "bad checksum", in: ":0400000001020304F3\n:00000001FF\n", err: "line 1: checksum mismatch"},
+		{name: "bad eof checksum", in: ":01000000AA55\n:00000001FE\n", err: "line 2: checksum mismatch"},
+		{name: "missing eof", in: ":0400000001020304F2\n", err: "missing end of file record"},
+		{name: "no data", in: ":00000001FF\n", err: "no data records"},
+		{name: "missing start code", in: "0400000001020304F2\n", err: "missing start code"},
+		{name: "bad length", in: ":0500000001020304F1\n", err: "invalid record length"},
+		{name: "bad hex", in: ":04000000010203ZZF2\n", err: "line 1"},
+		{name: "unknown type", in: ":00000006FA\n", err: "unknown record type 06"},
+		{name: "beyond max size", in: ":020000040010EA\n:01000000CC33\n:00000001FF\n", err: "beyond"},
+	})
+}
+
+func TestDecodeSREC(t *testing.T) {
+	runDecodeTests(t, decodeSREC, []decodeTest{
+		{name: "S1", in: "S006000043494D20\nS107000001020304EE\nS5030001FB\nS9030000FC\n", want: []byte{1, 2, 3, 4}},
+		{name: "gap", in: "S1040000AA51\nS1040003BB3D\nS9030000FC\n", want: []byte{0xAA, 0xFF, 0xFF, 0xBB}},
+		{name: "S2 24 bit address", in: "S205010000AA4F\nS804000000FB\n", want: fill(0x10000, 0xAA)},
+		{name: "S3 32 bit address", in: "S30600000010BB2E\nS70500000000FA\n", want: fill(0x10, 0xBB)},
+		{name: "records after termination ignored", in: "S1040000AA51\nS9030000FC\nS1040003BB3D\n", want: []byte{0xAA}},
+		{name: "missing termination", in: "S107000001020304EE\n", want: []byte{1, 2, 3, 4}},
+		{name: "bad checksum", in: "S107000001020304EF\nS9030000FC\n", err: "line 1: checksum mismatch"},
+		{name: "bad termination checksum", in: "S1040000AA51\nS9030000FD\n", err: "line 2: checksum mismatch"},
+		{name: "no data", in: "S006000043494D20\nS9030000FC\n", err: "no data records"},
+		{name: "missing start code", in: "107000001020304EE\n", err: "missing start code"},
+		{name: "unknown type", in: "S4030000FC\n", err: "unknown record type S4"},
+		{name: "bad length", in: "S108000001020304ED\n", err: "invalid record length"},
+		{name: "beyond max size", in: "S30600100000BB2E\n", err: "beyond"},
+	})
+}
+
+func TestDecodeHexText(t *testing.T) {
+	runDecodeTests(t, decodeHexText, []decodeTest{
+		{name: "spaced", in: "01 02\n0a0B\r\n", want: []byte{1, 2, 0x0A, 0x0B}},
+		{name: "odd digits", in: "01 2", err: "odd length"},
+		{name: "not hex", in: "01 0G", err: "invalid byte"},
+	})
+}
+
+func TestDecodeBase64(t *testing.T) {
+	runDecodeTests(t, decodeBase64, []decodeTest{
+		{name: "wrapped", in: "AQID\nBA==\n", want: []byte{1, 2, 3, 4}},
+		{name: "bad padding", in: "AQIDBA=", err: "illegal base64"},
+		{name: "not base64", in: "AQ!D", err: "illegal base64"},
+	})
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{Size, 1000, 0x10010} {
+		in := make([]byte, size)
+		r.Read(in)
+		for _, f := range []Format{FormatIHex, FormatSREC, FormatHexText, FormatBase64} {
+			if f == FormatSREC && size > 0x10000 {
+				continue
+			}
+			c, _ := CodecByFormat(f)
+			var buf bytes.Buffer
+			if err := c.Encode(&buf, in); err != nil {
+				t.Fatalf("%s %d: encode: %v", f, size, err)
+			}
+			if !c.Detect(buf.Bytes()) {
+				t.Errorf("%s %d: not detected", f, size)
+			}
+			out, err := c.Decode(buf.Bytes())
+			if err != nil {
+				t.Fatalf("%s %d: decode: %v", f, size, err)
+			}
+			if !bytes.Equal(out, in) {
+				t.Errorf("%s %d: round trip mismatch", f, size)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package cim
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Range is a span of bytes in the dump
+type Range struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("0x%03X+%d", r.Offset, r.Length)
+}
+
+// namedField is a value in the dump the way a user thinks about it, which
+// may be stored in several places (banks) or only part of a layout field
+type namedField struct {
+	name   string
+	ranges []Range
+	value  func(*Bin) string
+}
+
+var (
+	namedFieldList []namedField
+	namedFieldOnce sync.Once
+)
+
+// layoutRange returns the range of a layout field, or the size bytes of
+// element i of it
+func layoutRange(name string, i, size int) Range {
+	f, ok := FieldByName(name)
+	if !ok {
+		panic("unknown layout field " + name)
+	}
+	if size == 0 {
+		return Range{f.Offset, f.Length}
+	}
+	return Range{f.Offset + i*size, size}
+}
+
+func namedFields() []namedField {
+	namedFieldOnce.Do(func() {
+		r := func(name string) Range { return layoutRange(name, 0, 0) }
+		hex := func(b []byte) string { return fmt.Sprintf("%X", b) }
+		banks := func(a, b []byte) string {
+			if bytes.Equal(a, b) {
+				return hex(a)
+			}
+			return hex(a) + " / " + hex(b)
+		}
+
+		f := []namedField{
+			{"magic_byte", []Range{r("MagicByte")}, func(b *Bin) string { return fmt.Sprintf("%02X", b.MagicByte) }},
+			{"programming_date", []Range{r("ProgrammingDate")}, func(b *Bin) string { return b.ProgrammingDate.Format(IsoDate) }},
+			{"sas_option", []Range{r("SasOption")}, func(b *Bin) string { return fmt.Sprintf("%02X", b.SasOption) }},
+			{"unknown_bytes_1", []Range{r("UnknownBytes1")}, func(b *Bin) string { return hex(b.UnknownBytes1) }},
+			{"partno1", []Range{r("PartNo1")}, func(b *Bin) string { return fmt.Sprint(b.PartNo1) }},
+			{"partno1_rev", []Range{r("PartNo1Rev")}, func(b *Bin) string { return b.PartNo1Rev }},
+			{"configuration_version", []Range{r("ConfigurationVersion")}, func(b *Bin) string { return fmt.Sprint(b.ConfigurationVersion) }},
+			{"pnbase1", []Range{r("PnBase1")}, func(b *Bin) string { return fmt.Sprint(b.PnBase1) }},
+			{"pnbase1_rev", []Range{r("PnBase1Rev")}, func(b *Bin) string { return b.PnBase1Rev }},
+			{"vin", []Range{r("Vin.Data")}, func(b *Bin) string { return b.Vin.Data }},
+			{"vin.value", []Range{r("Vin.Value")}, func(b *Bin) string { return fmt.Sprint(b.Vin.Value) }},
+			{"vin.unknown", []Range{r("Vin.Unknown")}, func(b *Bin) string { return hex(b.Vin.Unknown) }},
+			{"sps_count", []Range{r("Vin.SpsCount")}, func(b *Bin) string { return fmt.Sprint(b.Vin.SpsCount) }},
+		}
+		for i := 0; i < 3; i++ {
+			i := i
+			f = append(f, namedField{fmt.Sprintf("programming_id[%d]", i), []Range{layoutRange("ProgrammingID", i, 10)}, func(b *Bin) string { return b.ProgrammingID[i] }})
+		}
+		f = append(f, []namedField{
+			{"unknown_data_3", []Range{r("UnknownData3.Data1"), r("UnknownData3.Data2")}, func(b *Bin) string { return banks(b.UnknownData3.Data1, b.UnknownData3.Data2) }},
+			{"pin", []Range{r("Pin.Data1"), r("Pin.Data2")}, func(b *Bin) string { return banks(b.Pin.Data1, b.Pin.Data2) }},
+			{"pin.unknown", []Range{r("Pin.Unknown1"), r("Pin.Unknown2")}, func(b *Bin) string { return banks(b.Pin.Unknown1, b.Pin.Unknown2) }},
+			{"unknown_data_4", []Range{r("UnknownData4.Data")}, func(b *Bin) string { return hex(b.UnknownData4.Data) }},
+			{"unknown_data_1", []Range{r("UnknownData1.Data1"), r("UnknownData1.Data2")}, func(b *Bin) string { return banks(b.UnknownData1.Data1, b.UnknownData1.Data2) }},
+			{"const1", []Range{r("Const1.Data")}, func(b *Bin) string { return hex(b.Const1.Data) }},
+			{"isk.high", []Range{r("Keys.IskHI1"), r("Keys.IskHI2")}, func(b *Bin) string { return banks(b.Keys.IskHI1, b.Keys.IskHI2) }},
+			{"isk.low", []Range{r("Keys.IskLO1"), r("Keys.IskLO2")}, func(b *Bin) string { return banks(b.Keys.IskLO1, b.Keys.IskLO2) }},
+		}...)
+		for i := 0; i < 5; i++ {
+			i := i
+			f = append(f, namedField{
+				fmt.Sprintf("key[%d]", i),
+				[]Range{layoutRange("Keys.Data1", i, 4), layoutRange("Keys.Data2", i, 4)},
+				func(b *Bin) string { return banks(b.Keys.Data1[i], b.Keys.Data2[i]) },
+			})
+		}
+		f = append(f, []namedField{
+			{"key_count", []Range{r("Keys.Count1"), r("Keys.Count2")}, func(b *Bin) string { return banks([]byte{b.Keys.Count1}, []byte{b.Keys.Count2}) }},
+			{"key.constant", []Range{r("Keys.Constant1"), r("Keys.Constant2")}, func(b *Bin) string { return banks(b.Keys.Constant1, b.Keys.Constant2) }},
+			{"key_errors", []Range{r("Keys.Errors1"), r("Keys.Errors2")}, func(b *Bin) string { return banks([]byte{b.Keys.Errors1}, []byte{b.Keys.Errors2}) }},
+			{"unknown_data_5", []Range{r("UnknownData5.Data")}, func(b *Bin) string { return hex(b.UnknownData5.Data) }},
+		}...)
+		for i := 0; i < 5; i++ {
+			i := i
+			f = append(f, namedField{fmt.Sprintf("sync[%d]", i), []Range{layoutRange("Sync.Data", i, 4)}, func(b *Bin) string { return hex(b.Sync.Data[i]) }})
+		}
+		f = append(f, []namedField{
+			{"unknown_data_6", []Range{r("UnknownData6.Data1"), r("UnknownData6.Data2")}, func(b *Bin) string { return banks(b.UnknownData6.Data1, b.UnknownData6.Data2) }},
+			{"unknown_data_7", []Range{r("UnknownData7.Data1"), r("UnknownData7.Data2")}, func(b *Bin) string { return banks(b.UnknownData7.Data1, b.UnknownData7.Data2) }},
+			{"unknown_data_8", []Range{r("UnknownData8.Data")}, func(b *Bin) string { return hex(b.UnknownData8.Data) }},
+			{"unknown_data_9", []Range{r("UnknownData9.Data")}, func(b *Bin) string { return hex(b.UnknownData9.Data) }},
+			{"unknown_data_2", []Range{r("UnknownData2.Data1"), r("UnknownData2.Data2")}, func(b *Bin) string { return banks(b.UnknownData2.Data1, b.UnknownData2.Data2) }},
+			{"snsticker", []Range{r("SnSticker")}, func(b *Bin) string { return fmt.Sprint(b.SnSticker) }},
+			{"factory_date", []Range{r("ProgrammingFactoryDate")}, func(b *Bin) string { return b.ProgrammingFactoryDate.Format(IsoDate) }},
+			{"unknown_bytes_2", []Range{r("UnknownBytes2")}, func(b *Bin) string { return hex(b.UnknownBytes2) }},
+			{"delphipn", []Range{r("DelphiPN")}, func(b *Bin) string { return fmt.Sprint(b.DelphiPN) }},
+			{"unknown_bytes_3", []Range{r("UnknownBytes3")}, func(b *Bin) string { return hex(b.UnknownBytes3) }},
+			{"partno", []Range{r("PartNo")}, func(b *Bin) string { return fmt.Sprint(b.PartNo) }},
+			{"unknown_data_14", []Range{r("UnknownData14")}, func(b *Bin) string { return hex(b.UnknownData14) }},
+			{"psk.low", []Range{r("PSK.Low")}, func(b *Bin) string { return hex(b.PSK.Low) }},
+			{"psk.high", []Range{r("PSK.High")}, func(b *Bin) string { return hex(b.PSK.High) }},
+			{"psk.constant", []Range{r("PSK.Constant")}, func(b *Bin) string { return hex(b.PSK.Constant) }},
+			{"psk.unknown", []Range{r("PSK.Unknown")}, func(b *Bin) string { return hex(b.PSK.Unknown) }},
+			{"unknown_data_10", []Range{r("UnknownData10.Data1"), r("UnknownData10.Data2")}, func(b *Bin) string { return banks(b.UnknownData10.Data1, b.UnknownData10.Data2) }},
+			{"eof", []Range{r("EOF")}, func(b *Bin) string { return fmt.Sprintf("%02X", b.EOF) }},
+		}...)
+		namedFieldList = f
+	})
+	return namedFieldList
+}
+
+func namedFieldByName(name string) (namedField, bool) {
+	for _, f := range namedFields() {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return namedField{}, false
+}
+
+// Change is a named field that differs between two dumps
+type Change struct {
+	Field  string  `json:"field"`
+	Ranges []Range `json:"ranges"`
+	Old    string  `json:"old"`
+	New    string  `json:"new"`
+}
+
+func (c Change) String() string {
+	var ranges []string
+	for _, r := range c.Ranges {
+		ranges = append(ranges, r.String())
+	}
+	return fmt.Sprintf("%s [%s]: %q -> %q", c.Field, strings.Join(ranges, ", "), c.Old, c.New)
+}
+
+// Diff returns every named field that differs between a and b. Differing
+// bytes that don't belong to a named field, such as checksums, are reported
+// under their layout field name, e.g. Keys.Checksum1
+func Diff(a, b *Bin) ([]Change, error) {
+	ab, err := a.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	bb, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	covered := make([]bool, len(ab))
+	for _, f := range namedFields() {
+		var changed bool
+		for _, r := range f.ranges {
+			for i := r.Offset; i < r.Offset+r.Length; i++ {
+				covered[i] = true
+			}
+			if !bytes.Equal(ab[r.Offset:r.Offset+r.Length], bb[r.Offset:r.Offset+r.Length]) {
+				changed = true
+			}
+		}
+		if changed {
+			changes = append(changes, Change{
+				Field:  f.name,
+				Ranges: f.ranges,
+				Old:    f.value(a),
+				New:    f.value(b),
+			})
+		}
+	}
+
+	for _, f := range Fields() {
+		r := Range{f.Offset, f.Length}
+		var uncovered bool
+		for i := r.Offset; i < r.Offset+r.Length; i++ {
+			if !covered[i] && ab[i] != bb[i] {
+				uncovered = true
+			}
+		}
+		if uncovered {
+			changes = append(changes, Change{
+				Field:  f.Name(),
+				Ranges: []Range{r},
+				Old:    fmt.Sprintf("%X", ab[r.Offset:r.Offset+r.Length]),
+				New:    fmt.Sprintf("%X", bb[r.Offset:r.Offset+r.Length]),
+			})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Ranges[0].Offset < changes[j].Ranges[0].Offset
+	})
+	return changes, nil
+}
@@ -0,0 +1,95 @@
+package cim
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dump := testDump(t)
+	a, err := LoadBytes("a.bin", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := LoadBytes("b.bin", append([]byte(nil), dump...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := Diff(a, same); err != nil || len(changes) != 0 {
+		t.Errorf("unchanged dump: %v %v", changes, err)
+	}
+
+	// Change the first named field of every section, in every bank
+	edited := append([]byte(nil), dump...)
+	var want []string
+	var checksums []string
+	for _, s := range Sections() {
+		bank := s.Banks[0]
+		for _, f := range namedFields() {
+			if r := f.ranges[0]; r.Offset >= bank.Offset && r.Offset < bank.Offset+bank.Length {
+				for _, r := range f.ranges {
+					edited[r.Offset] ^= 0x01
+				}
+				want = append(want, f.name)
+				break
+			}
+		}
+		for _, f := range Fields() {
+			if f.Path[0] == s.Name && strings.HasPrefix(f.Path[len(f.Path)-1], "Checksum") {
+				checksums = append(checksums, f.Name())
+			}
+		}
+	}
+	if len(want) != len(Sections()) {
+		t.Fatalf("found named fields %v for %d sections", want, len(Sections()))
+	}
+	b, err := LoadBytes("b.bin", edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Field)
+		f, ok := namedFieldByName(c.Field)
+		if !ok {
+			t.Errorf("unnamed change %v", c)
+			continue
+		}
+		if c.Old != f.value(a) || c.New != f.value(b) || c.Old == c.New {
+			t.Errorf("%s: %q -> %q, want %q -> %q", c.Field, c.Old, c.New, f.value(a), f.value(b))
+		}
+		if !reflect.DeepEqual(c.Ranges, f.ranges) {
+			t.Errorf("%s: ranges %v, want %v", c.Field, c.Ranges, f.ranges)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed fields %v, want %v", got, want)
+	}
+
+	// Checksums aren't named fields and show under their layout name
+	if err := b.UpdateChecksums(); err != nil {
+		t.Fatal(err)
+	}
+	changes, err = Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, c := range changes {
+		names[c.Field] = true
+	}
+	for _, name := range append(want, checksums...) {
+		if !names[name] {
+			t.Errorf("%s missing from %v", name, changes)
+		}
+	}
+	if len(changes) != len(want)+len(checksums) {
+		t.Errorf("%d changes, want %d", len(changes), len(want)+len(checksums))
+	}
+}
@@ -8,16 +8,21 @@ import (
 
 func (fw *Bin) Dump() {
 	fmt.Println("Bin file:", filepath.Base(fw.filename))
+	fmt.Println("Layout:", fw.Layout().Name)
+	fmt.Println("Byte swapped:", fw.Swapped())
 	fmt.Println("MD5:", fw.MD5())
 	fmt.Println("CRC32:", fw.CRC32())
 	fmt.Println("")
 
 	fmt.Println("VIN:", fw.Vin.Data)
+	for _, r := range fw.Vin.decodedRows() {
+		fmt.Printf("- %s: %s\n", r[0], r[1])
+	}
 	fmt.Printf("PIN: %X / %X\n", fw.Pin.Data1, fw.Pin.Data2)
 	fmt.Println("")
 
-	fmt.Printf("Model Year: %02s\n", fw.Vin.Data[9:10])
 	fmt.Printf("Steering Angle Sensor: %t\n", fw.SasOpt())
+	fmt.Printf("Virgin: %t\n", fw.IsVirgin())
 	fmt.Println("")
 
 	fmt.Println("Programmed keys:", fw.Keys.Count1)
@@ -65,4 +70,18 @@ func (fw *Bin) Dump() {
 	fmt.Printf("- SAAB part number: %d\n", fw.PartNo)
 	fmt.Printf("- Configuration Version: %d\n", fw.ConfigurationVersion)
 	fmt.Println()
+
+	if values, err := fw.OverlayValues(); err != nil {
+		fmt.Println("Overlay:", err)
+	} else if len(values) > 0 {
+		fmt.Println("Overlay:")
+		for _, v := range values {
+			fmt.Printf("- %s (%s %s): %s", v.Name, v.Range(), v.Type, v.Value)
+			if v.Description != "" {
+				fmt.Printf(" - %s", v.Description)
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
 }
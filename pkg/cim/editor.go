@@ -0,0 +1,136 @@
+package cim
+
+import "errors"
+
+// ErrEditorClosed is returned when using an Editor after Commit or Rollback
+var ErrEditorClosed = errors.New("editor is already committed or rolled back")
+
+// Editor is an editing session on a Bin. Edits are made on a working copy
+// through the embedded Bin and its setters, the Bin the session was started
+// on is only changed by a successful Commit.
+type Editor struct {
+	*Bin
+	target *Bin
+	orig   []byte
+	closed bool
+}
+
+// Edit starts an editing session on bin
+func (bin *Bin) Edit() (*Editor, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	work := &Bin{
+		filename: bin.filename,
+		encoding: bin.encoding,
+		layout:   bin.layout,
+		image:    bin.image,
+		format:   bin.format,
+		swapped:  bin.swapped,
+	}
+	if err := work.setBytes(b); err != nil {
+		return nil, err
+	}
+	return &Editor{
+		Bin:    work,
+		target: bin,
+		orig:   b,
+	}, nil
+}
+
+// Dirty returns the names of the checksummed sections changed so far
+func (e *Editor) Dirty() ([]string, error) {
+	b, err := e.Bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return dirtySections(changedRanges(e.orig, b)), nil
+}
+
+// Commit recalculates the checksum of every changed bank, validates the
+// changed sections and writes the result to the edited Bin. It returns the
+// byte ranges that differ from the Bin as it was when the session started,
+// checksums included. On a validation error the *ValidationReport is
+// returned and the session stays open to be fixed or rolled back.
+func (e *Editor) Commit() ([]Range, error) {
+	if e.closed {
+		return nil, ErrEditorClosed
+	}
+	b, err := e.Bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	changed := changedRanges(e.orig, b)
+	updateChecksums(b, changed)
+
+	dirty := make(map[string]bool)
+	for _, name := range dirtySections(changed) {
+		dirty[name] = true
+	}
+	r := &ValidationReport{Filename: e.target.filename}
+	for _, p := range validateSections(b, e.Bin.Layout()) {
+		var section string
+		switch err := p.(type) {
+		case *ChecksumError:
+			section = err.Section
+		case *BankMismatchError:
+			section = err.Section
+		}
+		if dirty[section] {
+			r.Problems = append(r.Problems, p)
+		}
+	}
+	if !r.OK() {
+		return nil, r
+	}
+
+	if err := e.target.setBytes(b); err != nil {
+		return nil, err
+	}
+	e.closed = true
+	return changedRanges(e.orig, b), nil
+}
+
+// Rollback discards every edit, the edited Bin is left untouched
+func (e *Editor) Rollback() error {
+	if e.closed {
+		return ErrEditorClosed
+	}
+	e.closed = true
+	return e.Bin.setBytes(e.orig)
+}
+
+// changedRanges returns the runs of bytes that differ between a and b
+func changedRanges(a, b []byte) []Range {
+	var ranges []Range
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Length == i {
+			ranges[n-1].Length++
+			continue
+		}
+		ranges = append(ranges, Range{Offset: i, Length: 1})
+	}
+	return ranges
+}
+
+// dirtySections returns the names of the sections, in layout order, with a
+// bank or checksum overlapping one of the ranges
+func dirtySections(ranges []Range) []string {
+	var names []string
+	for _, s := range Sections() {
+	banks:
+		for _, bank := range s.Banks {
+			for _, r := range ranges {
+				if r.Offset < bank.ChecksumOffset+2 && bank.Offset < r.Offset+r.Length {
+					names = append(names, s.Name)
+					break banks
+				}
+			}
+		}
+	}
+	return names
+}
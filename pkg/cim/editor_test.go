@@ -0,0 +1,152 @@
+package cim
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEditorCommit(t *testing.T) {
+	dump := testDump(t)
+	fw, err := LoadBytes("test.bin", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := fw.Edit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Plain field writes, the checksums are left to Commit
+	e.SasOption = 0x03
+	e.Keys.Constant1[0] ^= 0x55
+	e.Keys.Constant2[0] ^= 0x55
+
+	dirty, err := e.Dirty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dirty, []string{"Keys"}) {
+		t.Errorf("Dirty() = %v, want [Keys]", dirty)
+	}
+	if b, _ := fw.Bytes(); !bytes.Equal(b, dump) {
+		t.Errorf("target changed before Commit")
+	}
+
+	touched, err := e.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := changedRanges(dump, b); !reflect.DeepEqual(touched, want) {
+		t.Errorf("touched = %v, want %v", touched, want)
+	}
+	sas, _ := FieldByName("SasOption")
+	c1, _ := FieldByName("Keys.Constant1")
+	c2, _ := FieldByName("Keys.Constant2")
+	for _, off := range []int{sas.Offset, c1.Offset, c2.Offset} {
+		if !covers(touched, off) {
+			t.Errorf("0x%03X missing from touched %v", off, touched)
+		}
+	}
+	for _, s := range Sections() {
+		if s.Name != "Keys" {
+			continue
+		}
+		for _, bank := range s.Banks {
+			if !covers(touched, bank.ChecksumOffset) && !covers(touched, bank.ChecksumOffset+1) {
+				t.Errorf("checksum of bank %d not updated", bank.No)
+			}
+		}
+	}
+	if err := fw.Validate(); err != nil {
+		t.Errorf("committed dump doesn't validate: %v", err)
+	}
+	if fw.SasOption != 0x03 {
+		t.Errorf("SasOption = %X, want 03", fw.SasOption)
+	}
+
+	if _, err := e.Commit(); !errors.Is(err, ErrEditorClosed) {
+		t.Errorf("second Commit: %v, want ErrEditorClosed", err)
+	}
+	if err := e.Rollback(); !errors.Is(err, ErrEditorClosed) {
+		t.Errorf("Rollback after Commit: %v, want ErrEditorClosed", err)
+	}
+}
+
+func TestEditorBrokenChecksum(t *testing.T) {
+	dump := testDump(t)
+	fw, err := LoadBytes("test.bin", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := fw.Edit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only data changes recalculate a checksum
+	e.Keys.Checksum1 ^= 0xFFFF
+
+	_, err = e.Commit()
+	var report *ValidationReport
+	if !errors.As(err, &report) {
+		t.Fatalf("Commit: %v, want a *ValidationReport", err)
+	}
+	var ce *ChecksumError
+	if !errors.As(report, &ce) || ce.Section != "Keys" || ce.Bank.No != 1 {
+		t.Errorf("report %v, want a Keys bank 1 checksum error", report)
+	}
+	if b, _ := fw.Bytes(); !bytes.Equal(b, dump) {
+		t.Errorf("target changed by a failed Commit")
+	}
+
+	// Still open, fix it and commit
+	e.Keys.Checksum1 ^= 0xFFFF
+	touched, err := e.Commit()
+	if err != nil {
+		t.Fatalf("Commit after fixing: %v", err)
+	}
+	if len(touched) != 0 {
+		t.Errorf("touched = %v, want none", touched)
+	}
+}
+
+func TestEditorRollback(t *testing.T) {
+	dump := testDump(t)
+	fw, err := LoadBytes("test.bin", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := fw.Edit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Vin.Set("YS3FH41U181012345"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Keys.AddKey([]byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := fw.Bytes(); !bytes.Equal(b, dump) {
+		t.Errorf("target changed by Rollback")
+	}
+	if _, err := e.Commit(); !errors.Is(err, ErrEditorClosed) {
+		t.Errorf("Commit after Rollback: %v, want ErrEditorClosed", err)
+	}
+}
+
+// covers reports if off lies in one of the ranges
+func covers(ranges []Range, off int) bool {
+	for _, r := range ranges {
+		if off >= r.Offset && off < r.Offset+r.Length {
+			return true
+		}
+	}
+	return false
+}
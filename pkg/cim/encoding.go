@@ -0,0 +1,67 @@
+package cim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Size of the canonical CIM eeprom dump in bytes, see DefaultLayout
+const Size = 512
+
+// ErrInvalidSize is returned, wrapped with details, when loading data that
+// no registered layout matches
+var ErrInvalidSize = errors.New("invalid dump size")
+
+// Encoding is how the dump was stored
+type Encoding int
+
+const (
+	// Plain is the decoded image, magic byte 0x20 at offset 0
+	Plain Encoding = iota
+	// Inverted is every byte XORed with 0xFF, as the eeprom is read by most programmers
+	Inverted
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case Plain:
+		return "plain"
+	case Inverted:
+		return "inverted"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// plausibility scores how much a decoded image looks like a CIM dump
+func plausibility(b []byte) int {
+	var score int
+	// Magic byte
+	if b[0] == 0x20 {
+		score += 4
+	}
+	// EOF marker, 0x00 in every dump seen so far
+	if b[Size-1] == 0x00 {
+		score++
+	}
+	// A VIN is printable ASCII, blank ones are padded with spaces
+	vin := true
+	f, _ := FieldByName("Vin.Data")
+	for _, c := range b[f.Offset : f.Offset+f.Length] {
+		if c < 0x20 || c > 0x7E {
+			vin = false
+			break
+		}
+	}
+	if vin {
+		score += 2
+	}
+	// Every bank with a verifying checksum
+	for _, s := range Sections() {
+		for _, bank := range s.Banks {
+			if bankValid(b, bank) {
+				score++
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,77 @@
+package cim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLoadBytesSize(t *testing.T) {
+	for _, n := range []int{0, 1, Size - 1, Size + 1, 2 * Size} {
+		_, err := LoadBytes("test.bin", make([]byte, n))
+		if !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("%d bytes: %v, want ErrInvalidSize", n, err)
+			continue
+		}
+		for _, want := range []string{"test.bin", fmt.Sprintf("got %d bytes", n), fmt.Sprintf("%d (%s)", Size, DefaultLayout.Name)} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%d bytes: %q doesn't mention %q", n, err, want)
+			}
+		}
+	}
+}
+
+func TestLoadBytesKeepsInput(t *testing.T) {
+	for _, enc := range []Encoding{Plain, Inverted} {
+		in := testDump(t)
+		if enc == Inverted {
+			in = invert(in)
+		}
+		orig := append([]byte(nil), in...)
+		fw, err := LoadBytes("test.bin", in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.SetSasOpt(true)
+		if err := fw.Vin.Set("YS3FH41U181012345"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Bytes(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.XORBytes(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(in, orig) {
+			t.Errorf("%v: caller's slice modified", enc)
+		}
+	}
+}
+
+func TestDetectEncodingDamagedMagic(t *testing.T) {
+	dump := testDump(t)
+	for _, magic := range []byte{0x00, 0xDF, 0xFF} {
+		plain := append([]byte(nil), dump...)
+		plain[0] = magic
+		for _, tc := range []struct {
+			in   []byte
+			want Encoding
+		}{
+			{plain, Plain},
+			{invert(plain), Inverted},
+		} {
+			fw, err := LoadBytes("test.bin", tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fw.Encoding() != tc.want {
+				t.Errorf("magic %02X: encoding %v, want %v", magic, fw.Encoding(), tc.want)
+			}
+			if b, _ := fw.Bytes(); !bytes.Equal(b, plain) {
+				t.Errorf("magic %02X %v: read wrong", magic, tc.want)
+			}
+		}
+	}
+}
@@ -2,11 +2,18 @@ package cim
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/roffe/cim/pkg/crc16"
 )
 
+// MaxKeys is the number of key slots
+const MaxKeys = 5
+
+// ErrKeySlotsFull is returned when adding a key to a CIM with MaxKeys keys
+var ErrKeySlotsFull = errors.New("all 5 key slots are in use")
+
 type Keys struct {
 	IskHI1    []byte   `bin:"len:4" json:"isk_hi1"`
 	IskLO1    []byte   `bin:"len:2" json:"isk_lo1"`
@@ -26,7 +33,7 @@ type Keys struct {
 
 // Set key count
 func (k *Keys) Count(no uint8) error {
-	if no > 5 {
+	if no > MaxKeys {
 		return fmt.Errorf("max 5 keys")
 	}
 	k.Count1 = no
@@ -37,87 +44,117 @@ func (k *Keys) Count(no uint8) error {
 
 // SetKey set key value,0 is the first key
 func (k *Keys) SetKey(keyno uint8, value []byte) error {
-	if keyno > 5 {
+	if keyno >= MaxKeys {
 		return fmt.Errorf("invalid key position")
 	}
 	if len(value) != 4 {
 		return fmt.Errorf("invalid key size")
 	}
-	k.Data1[keyno], k.Data2[keyno] = value, value
+	k.setSlot(keyno, value)
 	k.updateChecksum()
 	return nil
 }
 
-func (k *Keys) SetErrorCount(value uint8) error {
-	k.Errors1, k.Errors2 = value, value
-	k.updateChecksum()
-	return nil
+// setSlot writes a copy of value to the slot in both banks
+func (k *Keys) setSlot(slot uint8, value []byte) {
+	k.Data1[slot] = append([]byte(nil), value...)
+	k.Data2[slot] = append([]byte(nil), value...)
 }
 
-func (k *Keys) SetKeyCount(keys uint8) error {
-	if keys > 5 {
-		return fmt.Errorf("maximum number of keys is 5")
+// AddKey programs id in the first free slot and returns the slot
+func (k *Keys) AddKey(id []byte) (uint8, error) {
+	if len(id) != 4 {
+		return 0, fmt.Errorf("invalid key size")
 	}
-	k.Count1, k.Count2 = keys, keys
-	return nil
-}
-
-func (k *Keys) SetIsk(high, low []byte) error {
-	if len(high) != 4 {
-		return fmt.Errorf("invalid isk high value length")
+	if isZero(id) {
+		return 0, fmt.Errorf("key id can't be zero")
 	}
-	if len(low) != 2 {
-		return fmt.Errorf("invalid isk low value length")
+	if k.Count1 >= MaxKeys {
+		return 0, ErrKeySlotsFull
 	}
-	k.IskHI1, k.IskHI2 = high, high
-	k.IskLO1, k.IskLO2 = low, low
+	for i := uint8(0); i < k.Count1; i++ {
+		if bytes.Equal(k.Data1[i], id) {
+			return 0, fmt.Errorf("key %X is already programmed in slot %d", id, i)
+		}
+	}
+	slot := k.Count1
+	k.setSlot(slot, id)
+	k.Count1, k.Count2 = slot+1, slot+1
 	k.updateChecksum()
-	return nil
+	return slot, nil
 }
 
-func (k *Keys) validate() error {
-	if k.Checksum1 != k.Checksum2 {
-		return fmt.Errorf("key 0 checksums missmatch in bin")
+// RemoveKey removes the key in slot, the keys after it move up one slot
+func (k *Keys) RemoveKey(slot uint8) error {
+	if k.Count1 > MaxKeys {
+		return fmt.Errorf("invalid key count %d", k.Count1)
 	}
-
-	if k.Count1 != k.Count2 {
-		return fmt.Errorf("key count missmatch in data banks %d | %d", k.Count1, k.Count2)
+	if slot >= k.Count1 {
+		return fmt.Errorf("slot %d is not in use, %d key(s) programmed", slot, k.Count1)
 	}
-
-	if k.Errors1 != k.Errors2 {
-		return fmt.Errorf("key errors missmatch in data banks %d | %d", k.Errors1, k.Errors2)
+	for i := slot; i+1 < k.Count1; i++ {
+		k.setSlot(i, k.Data1[i+1])
 	}
+	k.setSlot(k.Count1-1, make([]byte, 4))
+	k.Count1, k.Count2 = k.Count1-1, k.Count1-1
+	k.updateChecksum()
+	return nil
+}
 
-	if !bytes.Equal(k.Data1[0], k.Data2[0]) {
-		return fmt.Errorf("key 1, bank 1 and 2 does not match, corrupt memory?")
+// MoveKey moves the key in slot from to slot to, the keys in between shift
+// one slot towards from
+func (k *Keys) MoveKey(from, to uint8) error {
+	if k.Count1 > MaxKeys {
+		return fmt.Errorf("invalid key count %d", k.Count1)
 	}
-
-	if !bytes.Equal(k.Data1[1], k.Data2[1]) {
-		return fmt.Errorf("key 2, bank 1 and 2 does not match, corrupt memory?")
+	if from >= k.Count1 || to >= k.Count1 {
+		return fmt.Errorf("can't move key %d to %d, %d key(s) programmed", from, to, k.Count1)
 	}
-
-	if !bytes.Equal(k.Data1[2], k.Data2[2]) {
-		return fmt.Errorf("key 3, bank 1 and 2 does not match, corrupt memory?")
+	id := k.Data1[from]
+	for i := from; i < to; i++ {
+		k.setSlot(i, k.Data1[i+1])
 	}
-
-	if !bytes.Equal(k.Data1[3], k.Data2[3]) {
-		return fmt.Errorf("key 4, bank 1 and 2 does not match, corrupt memory?")
+	for i := from; i > to; i-- {
+		k.setSlot(i, k.Data1[i-1])
 	}
+	k.setSlot(to, id)
+	k.updateChecksum()
+	return nil
+}
 
-	if !bytes.Equal(k.Data1[4], k.Data2[4]) {
-		return fmt.Errorf("key 5, bank 1 and 2 does not match, corrupt memory?")
+// ClearUnusedSlots zeroes every slot after the programmed keys in both banks
+func (k *Keys) ClearUnusedSlots() {
+	for i := k.Count1; i < MaxKeys; i++ {
+		k.setSlot(i, make([]byte, 4))
 	}
+	k.updateChecksum()
+}
 
-	c1, c2 := k.Crc16()
+func (k *Keys) SetErrorCount(value uint8) error {
+	k.Errors1, k.Errors2 = value, value
+	k.updateChecksum()
+	return nil
+}
 
-	if c1 != k.Checksum1 {
-		return fmt.Errorf("keys calculated bank 1 checksum %X does not match stored %X", c1, k.Checksum1)
+func (k *Keys) SetKeyCount(keys uint8) error {
+	if keys > MaxKeys {
+		return fmt.Errorf("maximum number of keys is 5")
 	}
+	k.Count1, k.Count2 = keys, keys
+	k.updateChecksum()
+	return nil
+}
 
-	if c2 != k.Checksum1 {
-		return fmt.Errorf("keys calculated bank 1 checksum %X does not match stored %X", c1, k.Checksum1)
+func (k *Keys) SetIsk(high, low []byte) error {
+	if len(high) != 4 {
+		return fmt.Errorf("invalid isk high value length")
 	}
-
+	if len(low) != 2 {
+		return fmt.Errorf("invalid isk low value length")
+	}
+	k.IskHI1, k.IskHI2 = high, high
+	k.IskLO1, k.IskLO2 = low, low
+	k.updateChecksum()
 	return nil
 }
 
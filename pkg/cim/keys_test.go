@@ -0,0 +1,95 @@
+package cim
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func key(n byte) []byte {
+	return []byte{0x10, 0x20, 0x30, n}
+}
+
+func TestKeySlots(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   int // keys programmed before op
+		op     func(k *Keys) error
+		want   []byte // last byte of the key in each programmed slot
+		err    error  // expected error, errAny for any
+		count  uint8
+		zeroed int // slots after the count that must be zeroed
+	}{
+		{"add", 2, func(k *Keys) error { _, err := k.AddKey(key(9)); return err }, []byte{1, 2, 9}, nil, 3, 0},
+		{"add to empty", 0, func(k *Keys) error { _, err := k.AddKey(key(9)); return err }, []byte{9}, nil, 1, 0},
+		{"add sixth", 5, func(k *Keys) error { _, err := k.AddKey(key(9)); return err }, []byte{1, 2, 3, 4, 5}, ErrKeySlotsFull, 5, 0},
+		{"add duplicate", 2, func(k *Keys) error { _, err := k.AddKey(key(2)); return err }, []byte{1, 2}, errAny, 2, 0},
+		{"add zero", 2, func(k *Keys) error { _, err := k.AddKey(make([]byte, 4)); return err }, []byte{1, 2}, errAny, 2, 0},
+		{"remove first", 3, func(k *Keys) error { return k.RemoveKey(0) }, []byte{2, 3}, nil, 2, 1},
+		{"remove last", 3, func(k *Keys) error { return k.RemoveKey(2) }, []byte{1, 2}, nil, 2, 1},
+		{"remove empty slot", 3, func(k *Keys) error { return k.RemoveKey(3) }, []byte{1, 2, 3}, errAny, 3, 0},
+		{"move down", 4, func(k *Keys) error { return k.MoveKey(0, 2) }, []byte{2, 3, 1, 4}, nil, 4, 0},
+		{"move up", 4, func(k *Keys) error { return k.MoveKey(3, 1) }, []byte{1, 4, 2, 3}, nil, 4, 0},
+		{"move to itself", 2, func(k *Keys) error { return k.MoveKey(1, 1) }, []byte{1, 2}, nil, 2, 0},
+		{"move empty slot", 2, func(k *Keys) error { return k.MoveKey(2, 0) }, []byte{1, 2}, errAny, 2, 0},
+		{"clear unused", 2, func(k *Keys) error { k.ClearUnusedSlots(); return nil }, []byte{1, 2}, nil, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, err := LoadBytes("test.bin", testDump(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			k := &fw.Keys
+			for i := 0; i < MaxKeys; i++ {
+				// Leftovers past the count, as found on used modules
+				k.setSlot(uint8(i), key(0xF0+byte(i)))
+			}
+			k.Count1, k.Count2 = 0, 0
+			for i := 0; i < tt.keys; i++ {
+				if _, err := k.AddKey(key(byte(i + 1))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = tt.op(k)
+			switch {
+			case tt.err == errAny && err == nil:
+				t.Errorf("no error")
+			case tt.err != errAny && !errors.Is(err, tt.err):
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+
+			if k.Count1 != tt.count || k.Count2 != tt.count {
+				t.Errorf("count = %d/%d, want %d", k.Count1, k.Count2, tt.count)
+			}
+			var got []byte
+			for i := 0; i < int(k.Count1); i++ {
+				got = append(got, k.Data1[i][3])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %X, want %X", got, tt.want)
+			}
+			if !reflect.DeepEqual(k.Data1, k.Data2) {
+				t.Errorf("banks differ\nbank 1: %X\nbank 2: %X", k.Data1, k.Data2)
+			}
+			for i := int(k.Count1); i < int(k.Count1)+tt.zeroed; i++ {
+				if !isZero(k.Data1[i]) {
+					t.Errorf("slot %d not cleared: %X", i, k.Data1[i])
+				}
+			}
+
+			b, err := fw.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range validateSections(b, DefaultLayout) {
+				t.Errorf("%v", p)
+			}
+		})
+	}
+}
+
+// errAny matches any non nil error in table tests
+var errAny = fmt.Errorf("any error")
@@ -0,0 +1,386 @@
+package cim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Layout describes how a CIM revision stores the fields of the Bin struct.
+// Images are translated to the canonical 9-3 layout of Fields() on load and
+// back on save, so everything working on a Bin works on any registered
+// layout. Checksums are calculated on the canonical image, blocks must be
+// moved as a whole for them to stay valid.
+//
+// A layout can move fields and blocks and, for smaller or earlier revisions,
+// leave them out. It can't change the size or encoding of a field, a module
+// storing different fields, such as another Delphi part, needs its own Bin
+// struct.
+type Layout struct {
+	Name string `json:"name" yaml:"name"`
+	Size int    `json:"size" yaml:"size"` // Image size in bytes
+	// Detection rules, every rule must hold on the plain image
+	Magic       []MagicRule       `json:"magic,omitempty" yaml:"magic,omitempty"`
+	PartNumbers []PartNumberRange `json:"part_numbers,omitempty" yaml:"part_numbers,omitempty"`
+	// Blocks moves a field, e.g. Vin.Data, or a whole struct, e.g. Keys, to
+	// an image offset. Fields not listed keep their canonical offset.
+	Blocks map[string]int `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+	// Missing lists the fields or blocks, named as in Blocks, the revision
+	// doesn't store. They read as zero and are dropped on save, checksummed
+	// sections missing as a whole aren't validated.
+	Missing []string `json:"missing,omitempty" yaml:"missing,omitempty"`
+
+	offsets []int // image offset of every canonical byte, -1 if not stored
+}
+
+// MagicRule requires the plain image to hold Value at Offset
+type MagicRule struct {
+	Offset int  `json:"offset" yaml:"offset"`
+	Value  byte `json:"value" yaml:"value"`
+}
+
+// PartNumberRange requires the end model part number, PartNo1, to lie in
+// [Min, Max]
+type PartNumberRange struct {
+	Min uint32 `json:"min" yaml:"min"`
+	Max uint32 `json:"max" yaml:"max"`
+}
+
+// DefaultLayout is the 512 byte 9-3 CIM layout the Bin struct is declared
+// in. It has no detection rules and matches any image of its size.
+var DefaultLayout = mustResolve(&Layout{
+	Name: "9-3 CIM",
+	Size: Size,
+})
+
+var (
+	layoutsMu sync.RWMutex
+	layouts   = []*Layout{DefaultLayout}
+)
+
+func mustResolve(l *Layout) *Layout {
+	if err := l.resolve(); err != nil {
+		// Built in layouts are static, any error here is a programming error
+		panic(err)
+	}
+	return l
+}
+
+// RegisterLayout validates l and adds it to the layouts tried when loading
+func RegisterLayout(l *Layout) error {
+	if err := l.resolve(); err != nil {
+		return err
+	}
+	layoutsMu.Lock()
+	defer layoutsMu.Unlock()
+	for _, other := range layouts {
+		if other.Name == l.Name {
+			return fmt.Errorf("layout %s is already registered", l.Name)
+		}
+	}
+	layouts = append(layouts, l)
+	return nil
+}
+
+// Layouts returns the registered layouts in registration order
+func Layouts() []*Layout {
+	layoutsMu.RLock()
+	defer layoutsMu.RUnlock()
+	return append([]*Layout(nil), layouts...)
+}
+
+// LayoutByName returns the registered layout with the given name
+func LayoutByName(name string) (*Layout, bool) {
+	for _, l := range Layouts() {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// LoadLayout reads a YAML or JSON layout file and registers it, the format
+// is chosen by the .json, .yaml or .yml extension
+func LoadLayout(filename string) (*Layout, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var l Layout
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&l)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, &l)
+	default:
+		return nil, fmt.Errorf("%s: unknown layout format, use .json, .yaml or .yml", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse layout: %v", filename, err)
+	}
+	if err := RegisterLayout(&l); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return &l, nil
+}
+
+// resolve validates the layout and maps every canonical byte to the image
+func (l *Layout) resolve() error {
+	if l.Name == "" {
+		return fmt.Errorf("layout has no name")
+	}
+	if l.Size <= 0 {
+		return fmt.Errorf("layout %s: invalid size %d", l.Name, l.Size)
+	}
+	for _, m := range l.Magic {
+		if m.Offset < 0 || m.Offset >= l.Size {
+			return fmt.Errorf("layout %s: magic offset 0x%X is outside the %d byte image", l.Name, m.Offset, l.Size)
+		}
+	}
+	for _, r := range l.PartNumbers {
+		if r.Min > r.Max {
+			return fmt.Errorf("layout %s: part number range %d-%d is empty", l.Name, r.Min, r.Max)
+		}
+	}
+
+	offsets := make([]int, Size)
+	for i := range offsets {
+		offsets[i] = i
+	}
+	// Apply blocks in name order so errors are reproducible
+	names := make([]string, 0, len(l.Blocks))
+	for name := range l.Blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		start, end, ok := blockRange(name)
+		if !ok {
+			return fmt.Errorf("layout %s: unknown block %s", l.Name, name)
+		}
+		for i := start; i < end; i++ {
+			offsets[i] = l.Blocks[name] + i - start
+		}
+	}
+
+	for _, name := range l.Missing {
+		start, end, ok := blockRange(name)
+		if !ok {
+			return fmt.Errorf("layout %s: unknown missing block %s", l.Name, name)
+		}
+		if _, ok := l.Blocks[name]; ok {
+			return fmt.Errorf("layout %s: block %s is both moved and missing", l.Name, name)
+		}
+		for i := start; i < end; i++ {
+			offsets[i] = -1
+		}
+	}
+	// Checksums cover the canonical bytes, a bank with a missing part could
+	// never validate
+	for _, s := range Sections() {
+		stored := 0
+		total := 0
+		for _, bank := range s.Banks {
+			for i := bank.Offset; i < bank.ChecksumOffset+2; i++ {
+				if offsets[i] != -1 {
+					stored++
+				}
+				total++
+			}
+		}
+		if stored != 0 && stored != total {
+			return fmt.Errorf("layout %s: section %s is only partly missing", l.Name, s.Name)
+		}
+	}
+	if len(l.PartNumbers) > 0 {
+		if f, _ := FieldByName("PartNo1"); offsets[f.Offset] == -1 {
+			return fmt.Errorf("layout %s: part number rules need PartNo1, which is missing", l.Name)
+		}
+	}
+
+	owner := make(map[int]int)
+	for i, off := range offsets {
+		if off == -1 {
+			continue
+		}
+		if off < 0 || off >= l.Size {
+			return fmt.Errorf("layout %s: canonical byte 0x%03X maps to 0x%X outside the %d byte image", l.Name, i, off, l.Size)
+		}
+		if other, ok := owner[off]; ok {
+			return fmt.Errorf("layout %s: canonical bytes 0x%03X and 0x%03X both map to 0x%03X", l.Name, other, i, off)
+		}
+		owner[off] = i
+	}
+	l.offsets = offsets
+	return nil
+}
+
+// blockRange returns the canonical byte range of a field or top level struct
+func blockRange(name string) (int, int, bool) {
+	if f, ok := FieldByName(name); ok {
+		return f.Offset, f.Offset + f.Length, true
+	}
+	start, end := -1, -1
+	for _, f := range Fields() {
+		if f.Path[0] != name {
+			continue
+		}
+		if start == -1 {
+			start = f.Offset
+		}
+		end = f.Offset + f.Length
+	}
+	return start, end, start != -1
+}
+
+// matches reports if the plain image b satisfies every detection rule
+func (l *Layout) matches(b []byte) bool {
+	if len(b) != l.Size {
+		return false
+	}
+	for _, m := range l.Magic {
+		if b[m.Offset] != m.Value {
+			return false
+		}
+	}
+	if len(l.PartNumbers) == 0 {
+		return true
+	}
+	f, _ := FieldByName("PartNo1")
+	pn := make([]byte, f.Length)
+	for i := range pn {
+		pn[i] = b[l.offsets[f.Offset+i]]
+	}
+	partNo := binary.BigEndian.Uint32(pn)
+	for _, r := range l.PartNumbers {
+		if partNo >= r.Min && partNo <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the number of detection rules, more specific layouts win ties
+func (l *Layout) rules() int {
+	n := len(l.Magic)
+	if len(l.PartNumbers) > 0 {
+		n++
+	}
+	return n
+}
+
+// toCanonical translates the plain image b to the canonical layout, bytes
+// the layout doesn't store are zero
+func (l *Layout) toCanonical(b []byte) []byte {
+	out := make([]byte, Size)
+	for i, off := range l.offsets {
+		if off != -1 {
+			out[i] = b[off]
+		}
+	}
+	return out
+}
+
+// toImage translates the canonical image b to the layout. Image bytes that
+// hold no field are taken from base, or zero without one.
+func (l *Layout) toImage(b, base []byte) []byte {
+	out := make([]byte, l.Size)
+	copy(out, base)
+	for i, off := range l.offsets {
+		if off != -1 {
+			out[off] = b[i]
+		}
+	}
+	return out
+}
+
+// stores reports if the layout stores the section, resolve makes sure
+// sections are either stored or missing as a whole
+func (l *Layout) stores(s Section) bool {
+	return l.offsets[s.Banks[0].Offset] != -1
+}
+
+// detectLayout finds the registered layout and encoding out of encs one of
+// the variants of an image is most plausibly stored in, and returns the
+// index of that variant. Ties go to the layout with the most detection
+// rules, then to the candidate with the magic byte in place, then to the
+// earliest variant, then to the inverted encoding most programmers read the
+// eeprom in.
+func detectLayout(variants [][]byte, encs []Encoding) (*Layout, Encoding, int, error) {
+	var best *Layout
+	var bestEnc Encoding
+	var bestVariant, bestScore, bestRules int
+	var bestMagic bool
+	for v, b := range variants {
+		inverted := make([]byte, len(b))
+		for i, bb := range b {
+			inverted[i] = bb ^ 0xFF
+		}
+		for _, l := range Layouts() {
+			for _, enc := range encs {
+				data := b
+				if enc == Inverted {
+					data = inverted
+				}
+				if !l.matches(data) {
+					continue
+				}
+				canonical := l.toCanonical(data)
+				score, rules, magic := plausibility(canonical), l.rules(), canonical[0] == 0x20
+				better := best == nil
+				switch {
+				case better:
+				case score != bestScore:
+					better = score > bestScore
+				case rules != bestRules:
+					better = rules > bestRules
+				case magic != bestMagic:
+					better = magic
+				case v != bestVariant:
+					better = false
+				default:
+					better = enc == Inverted && bestEnc == Plain
+				}
+				if better {
+					best, bestEnc, bestVariant, bestScore, bestRules, bestMagic = l, enc, v, score, rules, magic
+				}
+			}
+		}
+	}
+	if best == nil {
+		var sizes []string
+		for _, l := range Layouts() {
+			sizes = append(sizes, fmt.Sprintf("%d (%s)", l.Size, l.Name))
+		}
+		return nil, 0, 0, fmt.Errorf("%w: got %d bytes, no registered layout matches, known sizes %s", ErrInvalidSize, len(variants[0]), strings.Join(sizes, ", "))
+	}
+	return best, bestEnc, bestVariant, nil
+}
+
+// Layout returns the layout the dump was detected in when it was loaded
+func (bin *Bin) Layout() *Layout {
+	if bin.layout == nil {
+		return DefaultLayout
+	}
+	return bin.layout
+}
+
+// Image returns the plain dump in the layout it was loaded from, see Bytes()
+// for the canonical image
+func (bin *Bin) Image() ([]byte, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return bin.Layout().toImage(b, bin.image), nil
+}
@@ -0,0 +1,221 @@
+package cim
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testDump returns a plausible canonical dump with valid checksums
+func testDump(t *testing.T) []byte {
+	t.Helper()
+	b := make([]byte, Size)
+	b[0] = 0x20
+	fw, err := LoadBytes("test.bin", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Vin.Set("YS3FD49Y691012345"); err != nil {
+		t.Fatal(err)
+	}
+	fw.SetPartNo1(12786543)
+	if err := fw.UpdateChecksums(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// withLayouts restores the layout registry when the test ends
+func withLayouts(t testing.TB) {
+	t.Helper()
+	saved := Layouts()
+	t.Cleanup(func() {
+		layoutsMu.Lock()
+		defer layoutsMu.Unlock()
+		layouts = saved
+	})
+}
+
+func mustRegister(t testing.TB, l *Layout) *Layout {
+	t.Helper()
+	if err := RegisterLayout(l); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestRegisterLayoutErrors(t *testing.T) {
+	withLayouts(t)
+	tests := []struct {
+		name   string
+		layout Layout
+		want   string
+	}{
+		{"no name", Layout{Size: Size}, "no name"},
+		{"no size", Layout{Name: "x"}, "invalid size"},
+		{"magic outside", Layout{Name: "x", Size: Size, Magic: []MagicRule{{Offset: Size}}}, "magic offset"},
+		{"empty range", Layout{Name: "x", Size: Size, PartNumbers: []PartNumberRange{{Min: 2, Max: 1}}}, "is empty"},
+		{"unknown block", Layout{Name: "x", Size: Size, Blocks: map[string]int{"Nope": 0}}, "unknown block"},
+		{"unknown missing", Layout{Name: "x", Size: Size, Missing: []string{"Nope"}}, "unknown missing block"},
+		{"moved and missing", Layout{Name: "x", Size: 1024, Blocks: map[string]int{"Sync": 0x200}, Missing: []string{"Sync"}}, "both moved and missing"},
+		{"partly missing", Layout{Name: "x", Size: Size, Missing: []string{"Vin.Data"}}, "only partly missing"},
+		{"part number missing", Layout{Name: "x", Size: Size, PartNumbers: []PartNumberRange{{Max: 1}}, Missing: []string{"PartNo1"}}, "need PartNo1"},
+		{"outside", Layout{Name: "x", Size: Size, Blocks: map[string]int{"Keys": 0x200}}, "outside"},
+		{"overlap", Layout{Name: "x", Size: Size, Blocks: map[string]int{"Keys": 0}}, "both map to"},
+		{"duplicate", Layout{Name: DefaultLayout.Name, Size: Size}, "already registered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.layout
+			err := RegisterLayout(&l)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if n := len(Layouts()); n != 1 {
+		t.Errorf("%d layouts registered, want 1", n)
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	withLayouts(t)
+	l := mustRegister(t, &Layout{
+		Name:    "test moved",
+		Size:    1024,
+		Blocks:  map[string]int{"Keys": 0x200, "Vin.Data": 0x300},
+		Missing: []string{"UnknownData8"},
+	})
+	dump := testDump(t)
+	want := append([]byte(nil), dump...)
+	start, end, _ := blockRange("UnknownData8")
+	for i := start; i < end; i++ {
+		want[i] = 0
+	}
+
+	base := bytes.Repeat([]byte{0xFF}, l.Size)
+	image := l.toImage(dump, base)
+	keys, keysEnd, _ := blockRange("Keys")
+	if !bytes.Equal(image[0x200:0x200+keysEnd-keys], dump[keys:keysEnd]) {
+		t.Errorf("keys not moved to 0x200")
+	}
+	if !bytes.Equal(image[keys:keysEnd], base[keys:keysEnd]) {
+		t.Errorf("old keys offset overwritten")
+	}
+	if !bytes.Equal(image[start:end], base[start:end]) {
+		t.Errorf("missing block written to the image")
+	}
+	if got := l.toCanonical(image); !bytes.Equal(got, want) {
+		t.Errorf("toCanonical(toImage()) mismatch\ngot:  %X\nwant: %X", got, want)
+	}
+
+	fw, err := LoadBytes("test.bin", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Layout() != l {
+		t.Fatalf("layout = %s, want %s", fw.Layout().Name, l.Name)
+	}
+	if got, _ := fw.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("Bytes() mismatch\ngot:  %X\nwant: %X", got, want)
+	}
+	if got, _ := fw.Image(); !bytes.Equal(got, image) {
+		t.Errorf("Image() mismatch\ngot:  %X\nwant: %X", got, image)
+	}
+	if r := fw.Report(); !r.OK() {
+		t.Errorf("missing section validated: %v", r)
+	}
+}
+
+func TestDetectLayoutMagic(t *testing.T) {
+	withLayouts(t)
+	a := mustRegister(t, &Layout{Name: "test a", Size: 1024, Magic: []MagicRule{{Offset: 0x3FF, Value: 0xA5}}})
+	b := mustRegister(t, &Layout{Name: "test b", Size: 1024, Magic: []MagicRule{{Offset: 0x3FF, Value: 0x5A}}, Blocks: map[string]int{"Keys": 0x200}})
+	dump := testDump(t)
+	for _, l := range []*Layout{a, b} {
+		image := l.toImage(dump, nil)
+		image[l.Magic[0].Offset] = l.Magic[0].Value
+		fw, err := LoadBytes("test.bin", image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fw.Layout() != l {
+			t.Errorf("layout = %s, want %s", fw.Layout().Name, l.Name)
+		}
+	}
+
+	image := a.toImage(dump, nil)
+	if _, err := LoadBytes("test.bin", image); err == nil {
+		t.Errorf("image matching no magic rule loaded")
+	}
+}
+
+func TestDetectLayoutPartNumbers(t *testing.T) {
+	withLayouts(t)
+	a := mustRegister(t, &Layout{Name: "test early", Size: 1024, PartNumbers: []PartNumberRange{{Min: 12700000, Max: 12799999}}})
+	b := mustRegister(t, &Layout{Name: "test late", Size: 1024, PartNumbers: []PartNumberRange{{Min: 12800000, Max: 12899999}}, Blocks: map[string]int{"PartNo1": 0x200}})
+	dump := testDump(t)
+
+	fw, err := LoadBytes("test.bin", a.toImage(dump, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Layout() != a {
+		t.Errorf("layout = %s, want %s", fw.Layout().Name, a.Name)
+	}
+
+	fw.SetPartNo1(12812345)
+	late, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, err = LoadBytes("test.bin", b.toImage(late, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Layout() != b {
+		t.Errorf("layout = %s, want %s", fw.Layout().Name, b.Name)
+	}
+	if fw.PartNo1 != 12812345 {
+		t.Errorf("PartNo1 = %d, want 12812345", fw.PartNo1)
+	}
+}
+
+func TestDetectLayoutTieBreaks(t *testing.T) {
+	withLayouts(t)
+	// Same mapping, both read the dump equally well
+	mustRegister(t, &Layout{Name: "test loose", Size: 1024})
+	strict := mustRegister(t, &Layout{Name: "test strict", Size: 1024, Magic: []MagicRule{{Offset: 0, Value: 0x20}}})
+	dump := testDump(t)
+	image := strict.toImage(dump, nil)
+
+	fw, err := LoadBytes("test.bin", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Layout() != strict {
+		t.Errorf("layout = %s, want the one with more rules", fw.Layout().Name)
+	}
+	if fw.Encoding() != Plain {
+		t.Errorf("encoding = %v, want plain", fw.Encoding())
+	}
+
+	inverted := make([]byte, len(image))
+	for i, b := range image {
+		inverted[i] = b ^ 0xFF
+	}
+	fw, err = LoadBytes("test.bin", inverted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Encoding() != Inverted {
+		t.Errorf("encoding = %v, want inverted", fw.Encoding())
+	}
+	if got, _ := fw.Bytes(); !bytes.Equal(got, dump) {
+		t.Errorf("inverted image read wrong\ngot:  %X\nwant: %X", got, dump)
+	}
+}
@@ -0,0 +1,243 @@
+package cim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/albenik/bcd"
+	"gopkg.in/yaml.v2"
+)
+
+// OverlayField is a user defined sub-field inside one of the unknown regions
+// of the layout
+type OverlayField struct {
+	Name string `json:"name" yaml:"name"`
+	// Region optionally names the layout field the offset is relative to,
+	// e.g. UnknownData1.Data1. Without it the offset is from the start of
+	// the dump.
+	Region      string `json:"region,omitempty" yaml:"region,omitempty"`
+	Offset      int    `json:"offset" yaml:"offset"`
+	Length      int    `json:"length" yaml:"length"`
+	Type        string `json:"type" yaml:"type"` // u8, u16le, u16be, u32, u32le, u32be, bcd, ascii or bitfield
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Range returns the absolute byte range of the field, see Overlay.Fields
+func (f OverlayField) Range() Range {
+	return Range{f.Offset, f.Length}
+}
+
+// Overlay annotates the unknown regions of the layout with user findings
+type Overlay struct {
+	Fields []OverlayField `json:"fields" yaml:"fields"`
+}
+
+// OverlayValue is an overlay field decoded from a dump
+type OverlayValue struct {
+	OverlayField
+	Value string `json:"value"`
+}
+
+// overlayTypeLengths are the fixed lengths of the overlay types, types not
+// listed take any length
+var overlayTypeLengths = map[string]int{
+	"u8":    1,
+	"u16le": 2,
+	"u16be": 2,
+	"u32":   4,
+	"u32le": 4,
+	"u32be": 4,
+}
+
+var overlayTypes = map[string]bool{
+	"u8": true, "u16le": true, "u16be": true, "u32": true, "u32le": true, "u32be": true,
+	"bcd": true, "ascii": true, "bitfield": true,
+}
+
+// LoadOverlay reads a YAML or JSON overlay file, the format is chosen by the
+// .json, .yaml or .yml extension
+func LoadOverlay(filename string) (*Overlay, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseOverlay(b, "json")
+	case ".yaml", ".yml":
+		return ParseOverlay(b, "yaml")
+	}
+	return nil, fmt.Errorf("%s: unknown overlay format, use .json, .yaml or .yml", filename)
+}
+
+// ParseOverlay parses and validates an overlay in the json or yaml format.
+// Region relative offsets are resolved to absolute offsets.
+func ParseOverlay(b []byte, format string) (*Overlay, error) {
+	var o Overlay
+	var err error
+	switch format {
+	case "json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&o)
+	case "yaml":
+		err = yaml.UnmarshalStrict(b, &o)
+	default:
+		return nil, fmt.Errorf("unknown overlay format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse overlay: %v", err)
+	}
+	if err := o.resolve(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// resolve validates the fields and makes every offset absolute
+func (o *Overlay) resolve() error {
+	names := make(map[string]bool)
+	owner := make(map[int]string)
+	for i := range o.Fields {
+		f := &o.Fields[i]
+		if f.Name == "" {
+			return fmt.Errorf("overlay field %d has no name", i)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("overlay field %s is defined twice", f.Name)
+		}
+		names[f.Name] = true
+
+		f.Type = strings.ToLower(f.Type)
+		if !overlayTypes[f.Type] {
+			return fmt.Errorf("overlay field %s: unknown type %q", f.Name, f.Type)
+		}
+		if n, ok := overlayTypeLengths[f.Type]; ok && f.Length == 0 {
+			f.Length = n
+		}
+		if n, ok := overlayTypeLengths[f.Type]; (ok && f.Length != n) || f.Length <= 0 {
+			return fmt.Errorf("overlay field %s: invalid length %d for type %s", f.Name, f.Length, f.Type)
+		}
+
+		if f.Region != "" {
+			lf, ok := FieldByName(f.Region)
+			if !ok {
+				return fmt.Errorf("overlay field %s: unknown region %s", f.Name, f.Region)
+			}
+			if f.Offset < 0 || f.Offset+f.Length > lf.Length {
+				return fmt.Errorf("overlay field %s: 0x%X+%d is outside region %s of %d bytes", f.Name, f.Offset, f.Length, f.Region, lf.Length)
+			}
+			f.Offset += lf.Offset
+			f.Region = ""
+		}
+
+		for i := f.Offset; i < f.Offset+f.Length; i++ {
+			if !unknownByte(i) {
+				return fmt.Errorf("overlay field %s: byte 0x%03X is not part of an unknown region", f.Name, i)
+			}
+			if other, ok := owner[i]; ok {
+				return fmt.Errorf("overlay field %s overlaps %s at 0x%03X", f.Name, other, i)
+			}
+			owner[i] = f.Name
+		}
+	}
+	return nil
+}
+
+// unknownByte reports if offset i belongs to a layout field whose meaning
+// is not known, such as UnknownData1.Data1 or Vin.Unknown. Checksums of the
+// unknown sections are known.
+func unknownByte(i int) bool {
+	for _, f := range Fields() {
+		if i < f.Offset || i >= f.Offset+f.Length {
+			continue
+		}
+		if strings.HasPrefix(f.Path[len(f.Path)-1], "Checksum") {
+			return false
+		}
+		for _, p := range f.Path {
+			if strings.HasPrefix(p, "Unknown") {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Decode returns the value of every overlay field in bin
+func (o *Overlay) Decode(bin *Bin) ([]OverlayValue, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]OverlayValue, 0, len(o.Fields))
+	for _, f := range o.Fields {
+		values = append(values, OverlayValue{
+			OverlayField: f,
+			Value:        overlayValue(f.Type, b[f.Offset:f.Offset+f.Length]),
+		})
+	}
+	return values, nil
+}
+
+func overlayValue(typ string, b []byte) string {
+	switch typ {
+	case "u8":
+		return fmt.Sprint(b[0])
+	case "u16le":
+		return fmt.Sprint(binary.LittleEndian.Uint16(b))
+	case "u16be":
+		return fmt.Sprint(binary.BigEndian.Uint16(b))
+	case "u32", "u32be":
+		return fmt.Sprint(binary.BigEndian.Uint32(b))
+	case "u32le":
+		return fmt.Sprint(binary.LittleEndian.Uint32(b))
+	case "bcd":
+		return fmt.Sprint(bcd.ToUint64(b))
+	case "ascii":
+		return strings.TrimRight(string(b), " \x00")
+	case "bitfield":
+		bits := make([]string, len(b))
+		for i, c := range b {
+			bits[i] = fmt.Sprintf("%08b", c)
+		}
+		return strings.Join(bits, " ")
+	}
+	return fmt.Sprintf("%X", b)
+}
+
+var (
+	overlayMu     sync.RWMutex
+	activeOverlay *Overlay
+)
+
+// SetOverlay sets the overlay applied by Pretty, Dump, Json and the web ui,
+// nil removes it
+func SetOverlay(o *Overlay) {
+	overlayMu.Lock()
+	defer overlayMu.Unlock()
+	activeOverlay = o
+}
+
+// ActiveOverlay returns the overlay set with SetOverlay, or nil
+func ActiveOverlay() *Overlay {
+	overlayMu.RLock()
+	defer overlayMu.RUnlock()
+	return activeOverlay
+}
+
+// OverlayValues decodes the active overlay from bin, nil without an overlay
+func (bin *Bin) OverlayValues() ([]OverlayValue, error) {
+	o := ActiveOverlay()
+	if o == nil {
+		return nil, nil
+	}
+	return o.Decode(bin)
+}
@@ -0,0 +1,127 @@
+package cim
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOverlay(t *testing.T) {
+	want := []OverlayField{
+		{Name: "counter", Offset: 199 + 2, Length: 2, Type: "u16le", Description: "a counter"},
+		{Name: "date", Offset: 327, Length: 3, Type: "bcd"},
+	}
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		err    string
+	}{
+		{
+			name:   "json",
+			format: "json",
+			in: `{"fields": [
+				{"name": "counter", "region": "UnknownData1.Data1", "offset": 2, "type": "U16LE", "description": "a counter"},
+				{"name": "date", "offset": 327, "length": 3, "type": "bcd"}
+			]}`,
+		},
+		{
+			name:   "yaml",
+			format: "yaml",
+			in: `fields:
+  - name: counter
+    region: UnknownData1.Data1
+    offset: 2
+    type: u16le
+    description: a counter
+  - name: date
+    offset: 327
+    length: 3
+    type: bcd
+`,
+		},
+		{
+			name:   "json unknown field",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "offset": 327, "type": "u8", "size": 1}]}`,
+			err:    "failed to parse overlay",
+		},
+		{
+			name:   "yaml unknown field",
+			format: "yaml",
+			in:     "fields:\n  - name: a\n    offset: 327\n    type: u8\n    size: 1\n",
+			err:    "failed to parse overlay",
+		},
+		{
+			name:   "outside region",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "region": "UnknownData1.Data1", "offset": 19, "type": "u16le"}]}`,
+			err:    "outside region",
+		},
+		{
+			name:   "negative offset",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "region": "UnknownData1.Data1", "offset": -1, "type": "u8"}]}`,
+			err:    "outside region",
+		},
+		{
+			name:   "past the dump",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "offset": 512, "type": "u8"}]}`,
+			err:    "not part of an unknown region",
+		},
+		{
+			name:   "known byte",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "offset": 27, "type": "u8"}]}`,
+			err:    "not part of an unknown region",
+		},
+		{
+			name:   "unknown region",
+			format: "yaml",
+			in:     "fields:\n  - name: a\n    region: Nope\n    offset: 0\n    type: u8\n",
+			err:    "unknown region",
+		},
+		{
+			name:   "overlap",
+			format: "json",
+			in:     `{"fields": [{"name": "a", "offset": 327, "type": "u16le"}, {"name": "b", "offset": 328, "type": "u8"}]}`,
+			err:    "overlaps",
+		},
+		{
+			name:   "malformed json",
+			format: "json",
+			in:     `{"fields": [{"name": "a",`,
+			err:    "failed to parse overlay",
+		},
+		{
+			name:   "malformed yaml",
+			format: "yaml",
+			in:     "fields:\n  - name: [a\n",
+			err:    "failed to parse overlay",
+		},
+		{
+			name:   "unknown format",
+			format: "toml",
+			in:     "",
+			err:    "unknown overlay format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := ParseOverlay([]byte(tt.in), tt.format)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(o.Fields, want) {
+				t.Errorf("fields %+v, want %+v", o.Fields, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package cim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Patch is a repeatable list of operations on a dump, such as a workshop
+// procedure. Fields are named the way Diff reports them, e.g. vin, pin,
+// psk.high or programming_id[0].
+//
+//	description: Marry a used CIM to the car
+//	ops:
+//	  - set: vin
+//	    value: YS3FD49Y481234567
+//	  - add: key
+//	    value: 1A2B3C4D
+//	  - remove: key[2]
+//	  - write: 0x1F0
+//	    value: "00FF"
+type Patch struct {
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Ops         []PatchOp `json:"ops" yaml:"ops"`
+}
+
+// PatchOp is a single operation, exactly one of Set, Add, Remove and Write
+// must be given
+type PatchOp struct {
+	Set    string `json:"set,omitempty" yaml:"set,omitempty"`       // Field to set to Value
+	Add    string `json:"add,omitempty" yaml:"add,omitempty"`       // "key", Value is the key id
+	Remove string `json:"remove,omitempty" yaml:"remove,omitempty"` // "key[slot]"
+	// Write stores the hex bytes of Value at an offset of the canonical
+	// image, the offsets Diff reports
+	Write *int   `json:"write,omitempty" yaml:"write,omitempty"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+func (op PatchOp) String() string {
+	switch {
+	case op.Set != "":
+		return fmt.Sprintf("set %s", op.Set)
+	case op.Add != "":
+		return fmt.Sprintf("add %s", op.Add)
+	case op.Remove != "":
+		return fmt.Sprintf("remove %s", op.Remove)
+	case op.Write != nil:
+		return fmt.Sprintf("write 0x%03X", *op.Write)
+	}
+	return "empty op"
+}
+
+// LoadPatch reads a YAML or JSON patch file, the format is chosen by the
+// .json, .yaml or .yml extension
+func LoadPatch(filename string) (*Patch, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var p Patch
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&p)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, &p)
+	default:
+		return nil, fmt.Errorf("%s: unknown patch format, use .json, .yaml or .yml", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse patch: %v", filename, err)
+	}
+	if err := p.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return &p, nil
+}
+
+// check reports the first op that isn't exactly one operation
+func (p *Patch) check() error {
+	for i, op := range p.Ops {
+		n := 0
+		for _, set := range []bool{op.Set != "", op.Add != "", op.Remove != "", op.Write != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("op %d: expected exactly one of set, add, remove and write, got %d", i+1, n)
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of bin with every op applied in order, through the
+// same setters as the web editor. The checksum of every changed bank is
+// recalculated and the changed sections validated, a *ValidationReport is
+// returned if they don't hold. bin is not modified.
+func (p *Patch) Apply(bin *Bin) (*Bin, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	out := &Bin{
+		filename: bin.filename,
+		encoding: bin.encoding,
+		layout:   bin.layout,
+		image:    bin.image,
+		format:   bin.format,
+		swapped:  bin.swapped,
+	}
+	if err := out.setBytes(b); err != nil {
+		return nil, err
+	}
+
+	e, err := out.Edit()
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range p.Ops {
+		if err := op.apply(e.Bin); err != nil {
+			return nil, fmt.Errorf("op %d: %s: %v", i+1, op, err)
+		}
+	}
+	if _, err := e.Commit(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (op PatchOp) apply(bin *Bin) error {
+	switch {
+	case op.Set != "":
+		name, i, err := indexedField(op.Set)
+		if err != nil {
+			return err
+		}
+		set, ok := patchSetters[name]
+		if !ok {
+			return fmt.Errorf("unknown field, settable fields %s", strings.Join(SettableFields(), ", "))
+		}
+		return set(bin, i, op.Value)
+	case op.Add == "key":
+		id, err := hex.DecodeString(op.Value)
+		if err != nil {
+			return fmt.Errorf("invalid key id: %v", err)
+		}
+		_, err = bin.Keys.AddKey(id)
+		return err
+	case op.Add != "":
+		return fmt.Errorf("only keys can be added")
+	case op.Remove != "":
+		name, i, err := indexedField(op.Remove)
+		if err != nil {
+			return err
+		}
+		if name != "key[]" {
+			return fmt.Errorf("only keys can be removed, e.g. key[0]")
+		}
+		return bin.Keys.RemoveKey(uint8(i))
+	}
+
+	data, err := hex.DecodeString(op.Value)
+	if err != nil {
+		return fmt.Errorf("invalid data: %v", err)
+	}
+	off := *op.Write
+	if off < 0 || off+len(data) > Size {
+		return fmt.Errorf("%d byte(s) at 0x%X don't fit the %d byte image", len(data), off, Size)
+	}
+	b, err := bin.Bytes()
+	if err != nil {
+		return err
+	}
+	copy(b[off:], data)
+	return bin.setBytes(b)
+}
+
+var indexRe = regexp.MustCompile(`^([a-z_.]+)\[(\d+)\]$`)
+
+// indexedField splits key[2] into key[] and 2, names without an index are
+// returned as is with index -1
+func indexedField(name string) (string, int, error) {
+	m := indexRe.FindStringSubmatch(name)
+	if m == nil {
+		return name, -1, nil
+	}
+	i, err := strconv.Atoi(m[2])
+	if err != nil || i > 255 {
+		return "", 0, fmt.Errorf("invalid index %s", m[2])
+	}
+	return m[1] + "[]", i, nil
+}
+
+// patchSetters set a named field from its patch value, i is the index of
+// indexed fields
+var patchSetters = map[string]func(bin *Bin, i int, v string) error{
+	"vin": func(bin *Bin, _ int, v string) error { return bin.Vin.Set(v) },
+	"vin.value": func(bin *Bin, _ int, v string) error {
+		return setUint8(v, func(n uint8) error { bin.Vin.SetValue(n); return nil })
+	},
+	"sps_count": func(bin *Bin, _ int, v string) error {
+		return setUint8(v, func(n uint8) error { bin.Vin.SetSpsCount(n); return nil })
+	},
+	"pin":         func(bin *Bin, _ int, v string) error { return bin.Pin.Set(v) },
+	"psk.high":    func(bin *Bin, _ int, v string) error { return setHex(v, bin.PSK.SetHigh) },
+	"psk.low":     func(bin *Bin, _ int, v string) error { return setHex(v, bin.PSK.SetLow) },
+	"sas_option":  func(bin *Bin, _ int, v string) error { return setBool(v, bin.SetSasOpt) },
+	"partno1_rev": func(bin *Bin, _ int, v string) error { return bin.SetPartNo1Rev(v) },
+	"pnbase1_rev": func(bin *Bin, _ int, v string) error { return bin.SetPnBase1Rev(v) },
+	"isk.high": func(bin *Bin, _ int, v string) error {
+		return setHex(v, func(high []byte) error { return bin.Keys.SetIsk(high, bin.Keys.IskLO1) })
+	},
+	"isk.low": func(bin *Bin, _ int, v string) error {
+		return setHex(v, func(low []byte) error { return bin.Keys.SetIsk(bin.Keys.IskHI1, low) })
+	},
+	"key[]": func(bin *Bin, i int, v string) error {
+		return setHex(v, func(id []byte) error { return bin.Keys.SetKey(uint8(i), id) })
+	},
+	"key_count":  func(bin *Bin, _ int, v string) error { return setUint8(v, bin.Keys.SetKeyCount) },
+	"key_errors": func(bin *Bin, _ int, v string) error { return setUint8(v, bin.Keys.SetErrorCount) },
+	"sync[]": func(bin *Bin, i int, v string) error {
+		return setHex(v, func(data []byte) error { return bin.Sync.SetData(uint8(i), data) })
+	},
+	"programming_id[]": func(bin *Bin, i int, v string) error { return bin.SetProgrammingID(i, v) },
+	"programming_date": func(bin *Bin, _ int, v string) error { return setDate(v, bin.SetProgrammingDate) },
+	"factory_date":     func(bin *Bin, _ int, v string) error { return setDate(v, bin.SetFactoryDate) },
+	"configuration_version": func(bin *Bin, _ int, v string) error {
+		return setUint32(v, bin.SetConfVer)
+	},
+	"partno1":  func(bin *Bin, _ int, v string) error { return setUint32(v, bin.SetPartNo1) },
+	"pnbase1":  func(bin *Bin, _ int, v string) error { return setUint32(v, bin.SetPnBase1) },
+	"delphipn": func(bin *Bin, _ int, v string) error { return setUint32(v, bin.SetDelphiPN) },
+	"partno":   func(bin *Bin, _ int, v string) error { return setUint32(v, bin.SetPartNo) },
+	"snsticker": func(bin *Bin, _ int, v string) error {
+		sn, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		return bin.SetSnSticker(sn)
+	},
+}
+
+// SettableFields returns the names of the fields a patch can set, sorted.
+// Indexed fields are listed as e.g. key[n].
+func SettableFields() []string {
+	names := make([]string, 0, len(patchSetters))
+	for name := range patchSetters {
+		names = append(names, strings.Replace(name, "[]", "[n]", 1))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setHex(v string, set func([]byte) error) error {
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return err
+	}
+	return set(b)
+}
+
+func setUint8(v string, set func(uint8) error) error {
+	n, err := strconv.ParseUint(v, 0, 8)
+	if err != nil {
+		return err
+	}
+	return set(uint8(n))
+}
+
+func setUint32(v string, set func(uint32)) error {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return err
+	}
+	set(uint32(n))
+	return nil
+}
+
+func setBool(v string, set func(bool)) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	set(b)
+	return nil
+}
+
+func setDate(v string, set func(time.Time) error) error {
+	date, err := time.Parse(IsoDate, v)
+	if err != nil {
+		return err
+	}
+	return set(date)
+}
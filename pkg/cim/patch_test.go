@@ -0,0 +1,67 @@
+package cim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPatchApply(t *testing.T) {
+	fw, err := LoadBytes("test.bin", make([]byte, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write, past := 0x005, 510 // UnknownBytes1, the last two bytes
+	p := &Patch{Ops: []PatchOp{
+		{Set: "vin", Value: "YS3FD49Y481234567"},
+		{Set: "programming_id[1]", Value: "CIMTOOL"},
+		{Add: "key", Value: "1A2B3C4D"},
+		{Add: "key", Value: "5E6F7081"},
+		{Remove: "key[0]"},
+		{Write: &write, Value: "BEEF"},
+	}}
+	out, err := p.Apply(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Vin.Data != "YS3FD49Y481234567" {
+		t.Errorf("vin = %q", out.Vin.Data)
+	}
+	if out.ProgrammingID[1] != "CIMTOOL   " {
+		t.Errorf("programming_id[1] = %q", out.ProgrammingID[1])
+	}
+	if out.Keys.Count1 != 1 || !bytes.Equal(out.Keys.Data2[0], []byte{0x5E, 0x6F, 0x70, 0x81}) {
+		t.Errorf("keys = %d %X", out.Keys.Count1, out.Keys.Data2)
+	}
+	b, err := out.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[write:write+2], []byte{0xBE, 0xEF}) {
+		t.Errorf("write = %X", b[write:write+2])
+	}
+	for _, p := range validateSections(b, DefaultLayout) {
+		if err, ok := p.(*ChecksumError); ok && (err.Section == "Vin" || err.Section == "Keys") {
+			t.Errorf("checksum not updated: %v", err)
+		}
+	}
+	if now, _ := fw.Bytes(); !bytes.Equal(now, orig) {
+		t.Error("Apply modified its input")
+	}
+
+	for _, bad := range []*Patch{
+		{Ops: []PatchOp{{Set: "nope", Value: "1"}}},
+		{Ops: []PatchOp{{Set: "pin", Value: "123"}}},
+		{Ops: []PatchOp{{Remove: "key[0]"}}},
+		{Ops: []PatchOp{{Set: "vin", Add: "key"}}},
+		{Ops: []PatchOp{{Write: &past, Value: "00000000"}}},
+	} {
+		if _, err := bad.Apply(fw); err == nil {
+			t.Errorf("%v applied", bad.Ops[0])
+		}
+	}
+}
@@ -16,28 +16,6 @@ type Pin struct {
 	Checksum2 uint16 `bin:"le,len:2" json:"checksum2"`
 } // 20 bytes
 
-func (p *Pin) validate() error {
-	p1crc, p2crc := p.Crc16()
-
-	if p.Checksum1 != p1crc {
-		return fmt.Errorf("pin 1 checksum in binary does not match calculated checksum")
-	}
-
-	if p.Checksum2 != p2crc {
-		return fmt.Errorf("pin 2 checksum in binary does not match calculated checksum")
-	}
-
-	if p1crc != p2crc {
-		return fmt.Errorf("calculated checksums does not match")
-	}
-
-	if p.Checksum1 != p.Checksum2 {
-		return fmt.Errorf("stored pin checksums in binary does not match")
-	}
-
-	return nil
-}
-
 func (p *Pin) Crc16() (uint16, uint16) {
 	return crc16.Calc(append(p.Data1[:], p.Unknown1[:]...)),
 		crc16.Calc(append(p.Data2[:], p.Unknown2[:]...))
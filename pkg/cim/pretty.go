@@ -17,14 +17,24 @@ var (
 func (fw *Bin) Pretty() {
 	t := s("CIM Dump analyser: " + filepath.Base(fw.filename))
 	t.AppendRows([]table.Row{
+		{"Layout", fw.Layout().Name},
+		{"Byte swapped", fw.Swapped()},
 		{"MD5", fw.MD5()},
 		{"Crc32", fw.CRC32()},
 		{"VIN", fw.Vin.Data},
-		{"Model Year", fw.Vin.Data[9:10]},
 		{"Steering Angle Sensor", fw.SasOption},
+		{"Virgin", fw.IsVirgin()},
 	})
 	t.Render()
 
+	if rows := fw.Vin.decodedRows(); len(rows) > 0 {
+		v := s("VIN: " + fw.Vin.Data)
+		for _, r := range rows {
+			v.AppendRow(table.Row{r[0], r[1]})
+		}
+		v.Render()
+	}
+
 	pin := s("Pin")
 	pin.AppendHeader(table.Row{"#", "Bank 1", "Bank 2"})
 	pin.AppendRow(table.Row{0, fmt.Sprintf("%X", fw.Pin.Data1), fmt.Sprintf("%X", fw.Pin.Data2)})
@@ -81,6 +91,17 @@ func (fw *Bin) Pretty() {
 		{"Configuration Version:", fw.ConfigurationVersion},
 	})
 	pn.Render()
+
+	if values, err := fw.OverlayValues(); err != nil {
+		fmt.Println("Overlay:", err)
+	} else if len(values) > 0 {
+		o := s("Overlay")
+		o.AppendHeader(table.Row{"Name", "Range", "Type", "Value", "Description"})
+		for _, v := range values {
+			o.AppendRow(table.Row{v.Name, v.Range(), v.Type, v.Value, v.Description})
+		}
+		o.Render()
+	}
 }
 
 func s(title string) table.Writer {
@@ -0,0 +1,187 @@
+package cim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Profile is how a chip and programmer combination expects the image to be
+// flashed
+type Profile struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Organisation is the word size the chip is read in, 8 or 16 bits
+	Organisation int `json:"organisation" yaml:"organisation"`
+	// LittleEndian stores 16 bit words low byte first, which swaps every byte
+	// pair compared to the 8 bit organisation
+	LittleEndian bool `json:"little_endian,omitempty" yaml:"little_endian,omitempty"`
+	Inverted     bool `json:"inverted,omitempty" yaml:"inverted,omitempty"` // Every byte XORed with 0xFF
+	// Size of the chip in bytes, the image is padded up to it with Pad. Zero
+	// is the size of the image.
+	Size int  `json:"size,omitempty" yaml:"size,omitempty"`
+	Pad  byte `json:"pad,omitempty" yaml:"pad,omitempty"` // Padding as written to the file
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = []*Profile{
+		{Name: "plain", Description: "Decoded image, for tools and diffing", Organisation: 8},
+		{Name: "93c66-x8", Description: "93C66 read in 8 bit organisation, as most programmers do", Organisation: 8, Inverted: true, Size: 512},
+		{Name: "93c66-x16", Description: "93C66 read in 16 bit organisation, high byte first", Organisation: 16, Inverted: true, Size: 512},
+		{Name: "93c66-x16-le", Description: "93C66 read in 16 bit organisation, low byte first", Organisation: 16, LittleEndian: true, Inverted: true, Size: 512},
+		{Name: "93c76-x8", Description: "93C76 read in 8 bit organisation, padded to 1 KiB", Organisation: 8, Inverted: true, Size: 1024, Pad: 0xFF},
+		{Name: "93c86-x8", Description: "93C86 read in 8 bit organisation, padded to 2 KiB", Organisation: 8, Inverted: true, Size: 2048, Pad: 0xFF},
+	}
+)
+
+func (p *Profile) check() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("profile has no name")
+	case p.Organisation != 8 && p.Organisation != 16:
+		return fmt.Errorf("profile %s: organisation must be 8 or 16 bits, got %d", p.Name, p.Organisation)
+	case p.LittleEndian && p.Organisation != 16:
+		return fmt.Errorf("profile %s: byte order only applies to the 16 bit organisation", p.Name)
+	case p.Size < 0 || (p.Organisation == 16 && p.Size%2 != 0):
+		return fmt.Errorf("profile %s: invalid size %d", p.Name, p.Size)
+	}
+	return nil
+}
+
+// RegisterProfile validates p and adds it to the known profiles
+func RegisterProfile(p *Profile) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	for _, other := range profiles {
+		if other.Name == p.Name {
+			return fmt.Errorf("profile %s is already registered", p.Name)
+		}
+	}
+	profiles = append(profiles, p)
+	return nil
+}
+
+// Profiles returns the registered profiles in registration order
+func Profiles() []*Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	return append([]*Profile(nil), profiles...)
+}
+
+// ProfileByName returns the registered profile with the given name
+func ProfileByName(name string) (*Profile, error) {
+	var names []string
+	for _, p := range Profiles() {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown profile %q, known profiles %v", name, names)
+}
+
+// Bytes returns the exact image p expects to be flashed, bin in its layout
+func (p *Profile) Bytes(bin *Bin) ([]byte, error) {
+	b, err := bin.Image()
+	if err != nil {
+		return nil, err
+	}
+	if p.Size > 0 && len(b) > p.Size {
+		return nil, fmt.Errorf("profile %s: %d byte image doesn't fit the %d byte chip", p.Name, len(b), p.Size)
+	}
+	if p.Inverted {
+		for i, bb := range b {
+			b[i] = bb ^ 0xFF
+		}
+	}
+	if p.LittleEndian {
+		if len(b)%2 != 0 {
+			return nil, fmt.Errorf("profile %s: %d bytes don't fill 16 bit words", p.Name, len(b))
+		}
+		swapWords(b)
+	}
+	for len(b) < p.Size {
+		b = append(b, p.Pad)
+	}
+	return b, nil
+}
+
+// EncodeProfile writes the image p expects in format f. The binary formats
+// write it as is, p decides the encoding. JSON and YAML hold fields rather
+// than an image and can't be used.
+func EncodeProfile(w io.Writer, bin *Bin, f Format, p *Profile) error {
+	c, ok := CodecByFormat(f)
+	if !ok {
+		return fmt.Errorf("unknown format %q", f)
+	}
+	switch f {
+	case FormatJSON, FormatYAML:
+		return fmt.Errorf("profiles don't apply to the %s format", f)
+	}
+	b, err := p.Bytes(bin)
+	if err != nil {
+		return err
+	}
+	if f == FormatRaw || f == FormatInverted {
+		_, err := w.Write(b)
+		return err
+	}
+	return c.Encode(w, b)
+}
+
+// Swapped reports if the dump was loaded from a byte swapped image, the
+// swap is undone on load
+func (bin *Bin) Swapped() bool {
+	return bin.swapped
+}
+
+func swapWords(b []byte) {
+	for i := 0; i+1 < len(b); i += 2 {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+}
+
+// variant is a candidate reading of a loaded image
+type variant struct {
+	data    []byte
+	swapped bool
+}
+
+// imageVariants returns b as is, with padding past the size of a layout cut
+// off and with every 16 bit word swapped, in order of preference
+func imageVariants(b []byte) []variant {
+	variants := []variant{{data: b}}
+	for _, l := range Layouts() {
+		if l.Size < len(b) && padding(b[l.Size:]) {
+			variants = append(variants, variant{data: b[:l.Size]})
+		}
+	}
+	for _, v := range variants {
+		if len(v.data)%2 != 0 {
+			continue
+		}
+		swapped := append([]byte(nil), v.data...)
+		swapWords(swapped)
+		variants = append(variants, variant{data: swapped, swapped: true})
+	}
+	return variants
+}
+
+// padding reports if b is filled with erased or zeroed bytes
+func padding(b []byte) bool {
+	if len(b) == 0 || (b[0] != 0xFF && b[0] != 0x00) {
+		return false
+	}
+	for _, c := range b {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
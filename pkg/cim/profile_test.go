@@ -0,0 +1,118 @@
+package cim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func invert(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = c ^ 0xFF
+	}
+	return out
+}
+
+func swapped(b []byte) []byte {
+	out := append([]byte(nil), b...)
+	swapWords(out)
+	return out
+}
+
+func TestProfileBytes(t *testing.T) {
+	dump := testDump(t)
+	fw, err := LoadBytes("test.bin", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := invert(dump)
+	tests := map[string][]byte{
+		"plain":        dump,
+		"93c66-x8":     inv,
+		"93c66-x16":    inv,
+		"93c66-x16-le": swapped(inv),
+		"93c76-x8":     append(append([]byte(nil), inv...), bytes.Repeat([]byte{0xFF}, 512)...),
+		"93c86-x8":     append(append([]byte(nil), inv...), bytes.Repeat([]byte{0xFF}, 1536)...),
+	}
+	if len(tests) != len(Profiles()) {
+		t.Errorf("%d built-in profiles tested, %d registered", len(tests), len(Profiles()))
+	}
+	for name, want := range tests {
+		p, err := ProfileByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Bytes(fw)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %X\nwant %X", name, got, want)
+		}
+
+		// Every written image loads back to the same dump
+		back, _, err := Decode(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+		if b, _ := back.Bytes(); !bytes.Equal(b, dump) {
+			t.Errorf("%s: loaded back as %X", name, b)
+		}
+		if back.Swapped() != p.LittleEndian {
+			t.Errorf("%s: Swapped() = %v", name, back.Swapped())
+		}
+	}
+}
+
+func TestProfileTooSmall(t *testing.T) {
+	withLayouts(t)
+	l := mustRegister(t, &Layout{Name: "test large", Size: 1024})
+	fw, err := LoadBytes("test.bin", l.toImage(testDump(t), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := ProfileByName("93c66-x8")
+	if _, err := p.Bytes(fw); err == nil {
+		t.Errorf("1024 byte image fit the 512 byte chip")
+	}
+}
+
+func TestLoadVariants(t *testing.T) {
+	dump := testDump(t)
+	pad := func(b []byte, size int, c byte) []byte {
+		return append(append([]byte(nil), b...), bytes.Repeat([]byte{c}, size-len(b))...)
+	}
+	tests := []struct {
+		name    string
+		in      []byte
+		enc     Encoding
+		swapped bool
+	}{
+		{"plain", dump, Plain, false},
+		{"inverted", invert(dump), Inverted, false},
+		{"swapped", swapped(dump), Plain, true},
+		{"swapped inverted", swapped(invert(dump)), Inverted, true},
+		{"1K 0xFF padded", pad(dump, 1024, 0xFF), Plain, false},
+		{"2K 0xFF padded", pad(dump, 2048, 0xFF), Plain, false},
+		{"2K 0x00 padded", pad(dump, 2048, 0x00), Plain, false},
+		{"1K 0xFF padded inverted", pad(invert(dump), 1024, 0xFF), Inverted, false},
+		{"2K 0xFF padded swapped", pad(swapped(dump), 2048, 0xFF), Plain, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw, _, err := Decode(bytes.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := fw.Bytes(); !bytes.Equal(b, dump) {
+				t.Errorf("got %X\nwant %X", b, dump)
+			}
+			if fw.Encoding() != tt.enc {
+				t.Errorf("encoding = %v, want %v", fw.Encoding(), tt.enc)
+			}
+			if fw.Swapped() != tt.swapped {
+				t.Errorf("Swapped() = %v, want %v", fw.Swapped(), tt.swapped)
+			}
+		})
+	}
+}
@@ -32,13 +32,6 @@ func (p *PSK) SetHigh(high []byte) error {
 	return nil
 }
 
-func (p *PSK) validate() error {
-	if p.Checksum != p.Crc16() {
-		return fmt.Errorf("psk data checksum does not match calculated checksum")
-	}
-	return nil
-}
-
 func (p *PSK) Crc16() uint16 {
 	var b []byte
 	b = append(b, p.Low...)
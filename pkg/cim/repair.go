@@ -0,0 +1,120 @@
+package cim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ByteChange is a single byte written by an operation on the dump
+type ByteChange struct {
+	Offset int  `json:"offset"`
+	Old    byte `json:"old"`
+	New    byte `json:"new"`
+}
+
+func (c ByteChange) String() string {
+	return fmt.Sprintf("0x%03X: %02X -> %02X", c.Offset, c.Old, c.New)
+}
+
+// RepairAction describes one bank restored from its mirror
+type RepairAction struct {
+	Section string       `json:"section"`
+	From    Bank         `json:"from"`
+	To      Bank         `json:"to"`
+	Changes []ByteChange `json:"changes"`
+}
+
+func (a RepairAction) String() string {
+	return fmt.Sprintf("%s: restored bank %d from bank %d, %d byte(s) changed", a.Section, a.To.No, a.From.No, len(a.Changes))
+}
+
+// RepairLog is the outcome of Repair
+type RepairLog struct {
+	Actions []RepairAction `json:"actions"`
+	// Remaining problems Repair could not fix
+	Unrepaired *ValidationReport `json:"unrepaired"`
+}
+
+func (l *RepairLog) String() string {
+	var out strings.Builder
+	for _, a := range l.Actions {
+		out.WriteString(a.String() + "\n")
+		for _, c := range a.Changes {
+			out.WriteString("  " + c.String() + "\n")
+		}
+	}
+	if len(l.Actions) == 0 {
+		out.WriteString("nothing repaired\n")
+	}
+	for _, p := range l.Unrepaired.Problems {
+		out.WriteString("unrepaired: " + p.Error() + "\n")
+	}
+	return out.String()
+}
+
+// Repair restores damaged banks of mirrored sections from their intact
+// mirror. A bank is only restored when its own checksum fails and the
+// other bank's verifies, anything else is left as is and reported in
+// RepairLog.Unrepaired.
+func (bin *Bin) Repair() (*RepairLog, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	log := &RepairLog{}
+	for _, s := range Sections() {
+		if !s.Mirrored() {
+			continue
+		}
+		b1, b2 := s.Banks[0], s.Banks[1]
+		if len(bankDiff(b, b1, b2)) == 0 {
+			continue
+		}
+		ok1, ok2 := bankValid(b, b1), bankValid(b, b2)
+		if ok1 == ok2 {
+			// Either both verify and we can't tell which one is right,
+			// or neither does and there is nothing to restore from
+			continue
+		}
+		from, to := b1, b2
+		if ok2 {
+			from, to = b2, b1
+		}
+		log.Actions = append(log.Actions, RepairAction{
+			Section: s.Name,
+			From:    from,
+			To:      to,
+			Changes: copyBank(b, from, to),
+		})
+	}
+
+	if len(log.Actions) > 0 {
+		if err := bin.setBytes(b); err != nil {
+			return nil, err
+		}
+	}
+	log.Unrepaired = bin.Report()
+	return log, nil
+}
+
+func bankValid(b []byte, bank Bank) bool {
+	stored, calc := bankChecksums(b, bank)
+	return stored == calc
+}
+
+// copyBank copies the data and checksum of bank from over bank to
+func copyBank(b []byte, from, to Bank) []ByteChange {
+	var changes []ByteChange
+	for i := 0; i < from.Length+2; i++ {
+		if b[to.Offset+i] != b[from.Offset+i] {
+			changes = append(changes, ByteChange{
+				Offset: to.Offset + i,
+				Old:    b[to.Offset+i],
+				New:    b[from.Offset+i],
+			})
+			b[to.Offset+i] = b[from.Offset+i]
+		}
+	}
+	return changes
+}
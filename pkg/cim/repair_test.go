@@ -0,0 +1,100 @@
+package cim
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func sectionByName(t *testing.T, name string) Section {
+	t.Helper()
+	for _, s := range Sections() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no section %s", name)
+	return Section{}
+}
+
+func TestRepair(t *testing.T) {
+	pin := sectionByName(t, "Pin")
+	b1, b2 := pin.Banks[0], pin.Banks[1]
+	tests := []struct {
+		name    string
+		corrupt []int // offsets XORed with 0x5A
+		from    Bank
+		to      Bank
+	}{
+		{"bank 1", []int{b1.Offset, b1.Offset + 3}, b2, b1},
+		{"bank 2", []int{b2.Offset + 7}, b1, b2},
+		{"bank 1 checksum", []int{b1.ChecksumOffset}, b2, b1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := testDump(t)
+			in := append([]byte(nil), dump...)
+			var want []ByteChange
+			for _, off := range tt.corrupt {
+				in[off] ^= 0x5A
+				want = append(want, ByteChange{Offset: off, Old: in[off], New: dump[off]})
+			}
+			fw, err := LoadBytes("test.bin", in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			log, err := fw.Repair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(log.Actions) != 1 {
+				t.Fatalf("%d actions, want 1: %v", len(log.Actions), log)
+			}
+			a := log.Actions[0]
+			if a.Section != "Pin" || a.From != tt.from || a.To != tt.to {
+				t.Errorf("action %v, want Pin from bank %d to bank %d", a, tt.from.No, tt.to.No)
+			}
+			if !reflect.DeepEqual(a.Changes, want) {
+				t.Errorf("changes %v, want %v", a.Changes, want)
+			}
+			if !log.Unrepaired.OK() {
+				t.Errorf("unrepaired: %v", log.Unrepaired)
+			}
+			if b, _ := fw.Bytes(); !bytes.Equal(b, dump) {
+				t.Errorf("repaired dump differs from the original")
+			}
+		})
+	}
+}
+
+func TestRepairBothBanks(t *testing.T) {
+	pin := sectionByName(t, "Pin")
+	in := testDump(t)
+	in[pin.Banks[0].Offset] ^= 0x01
+	in[pin.Banks[1].Offset+1] ^= 0x02
+	fw, err := LoadBytes("test.bin", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := fw.Repair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Actions) != 0 {
+		t.Errorf("repaired with both banks bad: %v", log.Actions)
+	}
+	var ce *ChecksumError
+	var be *BankMismatchError
+	if !errors.As(log.Unrepaired, &ce) || ce.Section != "Pin" {
+		t.Errorf("checksum error not reported: %v", log.Unrepaired)
+	}
+	if !errors.As(log.Unrepaired, &be) || be.Section != "Pin" {
+		t.Errorf("bank mismatch not reported: %v", log.Unrepaired)
+	}
+	if b, _ := fw.Bytes(); !bytes.Equal(b, in) {
+		t.Errorf("dump changed")
+	}
+}
@@ -0,0 +1,360 @@
+package cim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ImportOptions control FromJSON and FromYAML
+type ImportOptions struct {
+	// UpdateChecksums recalculates the checksum of every bank, for hand
+	// edited dumps
+	UpdateChecksums bool
+}
+
+// FromJSON rebuilds a Bin from the output of Json. Every layout field must
+// be present with its exact length, the decoded VIN and overlay values are
+// ignored. Unedited fields listed under "raw" get their original bytes back
+// and bytes of a layout's image the fields don't cover are taken from
+// "image", so a dump survives the round trip byte for byte.
+func FromJSON(b []byte, opts ImportOptions) (*Bin, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse dump: %v", err)
+	}
+	// Decoded from the fields, not part of the dump
+	delete(top, "overlay")
+
+	layout := DefaultLayout
+	if v, ok := top["layout"]; ok {
+		var name string
+		if err := json.Unmarshal(v, &name); err != nil {
+			return nil, fmt.Errorf("layout: %v", err)
+		}
+		l, ok := LayoutByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown layout %q", name)
+		}
+		layout = l
+		delete(top, "layout")
+	}
+
+	var base []byte
+	if v, ok := top["image"]; ok {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, fmt.Errorf("image: %v", err)
+		}
+		var err error
+		if base, err = hex.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("image: %v", err)
+		}
+		if len(base) != layout.Size {
+			return nil, fmt.Errorf("image: got %d bytes, layout %s is %d", len(base), layout.Name, layout.Size)
+		}
+		delete(top, "image")
+	}
+
+	var raw map[string]string
+	if v, ok := top["raw"]; ok {
+		if err := json.Unmarshal(v, &raw); err != nil {
+			return nil, fmt.Errorf("raw: %v", err)
+		}
+		delete(top, "raw")
+	}
+
+	if v, ok := top["vin"]; ok {
+		var vin map[string]json.RawMessage
+		if err := json.Unmarshal(v, &vin); err != nil {
+			return nil, fmt.Errorf("vin: %v", err)
+		}
+		delete(vin, "decoded")
+		v, err := json.Marshal(vin)
+		if err != nil {
+			return nil, err
+		}
+		top["vin"] = v
+	}
+
+	stripped, err := json.Marshal(top)
+	if err != nil {
+		return nil, err
+	}
+	fw := &Bin{}
+	d := json.NewDecoder(bytes.NewReader(stripped))
+	d.DisallowUnknownFields()
+	if err := d.Decode(fw); err != nil {
+		return nil, fmt.Errorf("failed to parse dump: %v", err)
+	}
+	if err := checkKeys(top, reflect.TypeOf(Bin{}), ""); err != nil {
+		return nil, err
+	}
+
+	if err := fw.applyRaw(raw); err != nil {
+		return nil, err
+	}
+	image, err := fw.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if opts.UpdateChecksums {
+		updateChecksums(image, []Range{{0, Size}})
+	}
+
+	out := &Bin{layout: layout, image: base}
+	if err := out.setBytes(image); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FromYAML rebuilds a Bin from the output of Yaml, see FromJSON
+func FromYAML(b []byte, opts ImportOptions) (*Bin, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse dump: %v", err)
+	}
+	v, err := jsonCompatible(v)
+	if err != nil {
+		return nil, err
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(j, opts)
+}
+
+// Yaml returns the same document as Json, as YAML
+func (bin *Bin) Yaml() ([]byte, error) {
+	j, err := bin.Json()
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	v, err := orderedJSON(d)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// checkKeys reports the first layout field missing from the JSON object
+func checkKeys(obj map[string]json.RawMessage, t reflect.Type, prefix string) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if tag, _ := parseTag(sf.Tag.Get("bin")); tag.ignore {
+			continue
+		}
+		key := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if key == "" {
+			key = sf.Name
+		}
+		v, ok := lookupKey(obj, key)
+		if !ok {
+			return fmt.Errorf("missing field %s%s", prefix, sf.Name)
+		}
+		if sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			var sub map[string]json.RawMessage
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return fmt.Errorf("%s%s: %v", prefix, sf.Name, err)
+			}
+			if err := checkKeys(sub, sf.Type, prefix+sf.Name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupKey finds key the way encoding/json matches object keys to fields
+func lookupKey(obj map[string]json.RawMessage, key string) (json.RawMessage, bool) {
+	if v, ok := obj[key]; ok {
+		return v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// applyRaw sets every field listed in raw, whose value is unedited, to the
+// value its raw bytes decode to. The bytes are kept for encoding, the same
+// way a loaded dump keeps them, as custom read functions such as BCD dates
+// can't encode every value they decode.
+func (bin *Bin) applyRaw(raw map[string]string) error {
+	image := make([]byte, Size)
+	for name, h := range raw {
+		f, ok := FieldByName(name)
+		if !ok {
+			return fmt.Errorf("raw: unknown field %s", name)
+		}
+		data, err := hex.DecodeString(h)
+		if err != nil {
+			return fmt.Errorf("raw: %s: %v", name, err)
+		}
+		if len(data) != f.Length {
+			return fmt.Errorf("raw: %s is %d bytes, expected %d", name, len(data), f.Length)
+		}
+
+		field := make([]byte, Size)
+		copy(field[f.Offset:], data)
+		var orig Bin
+		if err := orig.setBytes(field); err != nil {
+			return err
+		}
+		ov, v := fieldValue(&orig, f.Path), fieldValue(bin, f.Path)
+		same, err := jsonEqual(ov.Interface(), v.Interface())
+		if err != nil {
+			return err
+		}
+		if !same {
+			// Edited by hand, the new value wins
+			continue
+		}
+		v.Set(ov)
+		copy(image[f.Offset:], data)
+	}
+	bin.raw = image
+	return nil
+}
+
+// rawFields returns the hex bytes of the fields whose JSON value doesn't
+// encode back to them, such as invalid BCD dates and strings that aren't
+// UTF-8, nil if there are none
+func (bin *Bin) rawFields() (map[string]string, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	j, err := json.Marshal(bin)
+	if err != nil {
+		return nil, err
+	}
+	var fresh Bin
+	if err := json.Unmarshal(j, &fresh); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]string
+	for _, f := range Fields() {
+		orig := b[f.Offset : f.Offset+f.Length]
+		v := fieldValue(&fresh, f.Path)
+		lossless := reflect.DeepEqual(v.Interface(), fieldValue(bin, f.Path).Interface())
+		if f.fn != "" {
+			out, err := fieldWriters[f.fn](v)
+			lossless = err == nil && bytes.Equal(out, orig)
+		}
+		if lossless {
+			continue
+		}
+		if raw == nil {
+			raw = make(map[string]string)
+		}
+		raw[f.Name()] = fmt.Sprintf("%X", orig)
+	}
+	return raw, nil
+}
+
+// fieldValue returns the settable value of the field at path
+func fieldValue(bin *Bin, path []string) reflect.Value {
+	v := reflect.ValueOf(bin).Elem()
+	for _, name := range path {
+		v = v.FieldByName(name)
+	}
+	return v
+}
+
+func jsonEqual(a, b interface{}) (bool, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ja, jb), nil
+}
+
+// orderedJSON decodes the next JSON value keeping the key order of objects
+func orderedJSON(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		var m yaml.MapSlice
+		for d.More() {
+			k, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := orderedJSON(d)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yaml.MapItem{Key: k, Value: v})
+		}
+		_, err := d.Token()
+		return m, err
+	case json.Delim('['):
+		l := []interface{}{}
+		for d.More() {
+			v, err := orderedJSON(d)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		_, err := d.Token()
+		return l, err
+	}
+	if n, ok := t.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	}
+	return t, nil
+}
+
+// jsonCompatible turns the maps yaml.v2 decodes into ones encoding/json
+// can marshal
+func jsonCompatible(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non string key %v", k)
+			}
+			e, err := jsonCompatible(e)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = e
+		}
+		return m, nil
+	case []interface{}:
+		for i, e := range v {
+			e, err := jsonCompatible(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,115 @@
+package cim
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// FuzzTextRoundTrip ensures a dump written as JSON or YAML reads back to the
+// same bytes, whatever the fields hold, also in a layout with bytes the
+// fields don't cover
+func FuzzTextRoundTrip(f *testing.F) {
+	withLayouts(f)
+	l := mustRegister(f, &Layout{
+		Name:   "fuzz",
+		Size:   1024,
+		Magic:  []MagicRule{{Offset: 0x3FF, Value: 0xC3}},
+		Blocks: map[string]int{"Keys": 0x300},
+	})
+	f.Add(bytes.Repeat([]byte{0x20}, 512))
+	f.Add(bytes.Repeat([]byte{0xFF}, 512))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		if len(in) != 512 {
+			t.Skip()
+		}
+		fw, err := LoadBytes("fuzz.bin", in)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		base := make([]byte, l.Size)
+		for i := range base {
+			base[i] = in[i%len(in)] ^ byte(i>>9)
+		}
+		image := l.toImage(in, base)
+		image[0x3FF] = 0xC3
+		moved, err := LoadBytes("fuzz.bin", image)
+		if err != nil {
+			t.Fatalf("load %s: %v", l.Name, err)
+		}
+		if moved.Layout() != l {
+			t.Fatalf("loaded as %s", moved.Layout().Name)
+		}
+
+		for _, bin := range []*Bin{fw, moved} {
+			want, err := bin.Image()
+			if err != nil {
+				t.Fatalf("image: %v", err)
+			}
+			j, err := bin.Json()
+			if err != nil {
+				t.Fatalf("json: %v", err)
+			}
+			y, err := bin.Yaml()
+			if err != nil {
+				t.Fatalf("yaml: %v", err)
+			}
+			for _, tc := range []struct {
+				name string
+				read func([]byte, ImportOptions) (*Bin, error)
+				doc  []byte
+			}{
+				{"json", FromJSON, j},
+				{"yaml", FromYAML, y},
+			} {
+				back, err := tc.read(tc.doc, ImportOptions{})
+				if err != nil {
+					t.Fatalf("from %s: %v\n%s", tc.name, err, tc.doc)
+				}
+				if back.Layout() != bin.Layout() {
+					t.Fatalf("%s: layout %s, want %s", tc.name, back.Layout().Name, bin.Layout().Name)
+				}
+				got, err := back.Image()
+				if err != nil {
+					t.Fatalf("%s image: %v", tc.name, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%s %s round trip mismatch\nwant: %X\ngot:  %X", bin.Layout().Name, tc.name, want, got)
+				}
+			}
+		}
+	})
+}
+
+func TestFromJSONEdit(t *testing.T) {
+	fw, err := LoadBytes("test.bin", bytes.Repeat([]byte{0x20}, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := fw.Json()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := strings.Replace(string(j), `"sas_option": 32`, `"sas_option": 3`, 1)
+	back, err := FromJSON([]byte(edited), ImportOptions{UpdateChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.SasOption != 3 {
+		t.Errorf("SasOption = %d, want 3", back.SasOption)
+	}
+	if err := back.Validate(); err != nil {
+		t.Errorf("checksums not updated: %v", err)
+	}
+
+	short := strings.Replace(string(j), `"partno1_rev": "  "`, `"partno1_rev": " "`, 1)
+	if _, err := FromJSON([]byte(short), ImportOptions{}); err == nil || !strings.Contains(err.Error(), "PartNo1Rev") {
+		t.Errorf("short PartNo1Rev: got error %v", err)
+	}
+
+	missing := strings.Replace(string(j), `"eof": 32`, `"x": 1`, 1)
+	if _, err := FromJSON([]byte(missing), ImportOptions{}); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
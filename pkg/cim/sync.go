@@ -12,21 +12,17 @@ type Sync struct {
 } // 22 bytes
 
 func (s *Sync) SetData(no uint8, data []byte) error {
+	if int(no) >= len(s.Data) {
+		return fmt.Errorf("invalid sync position %d", no)
+	}
 	if len(data) != 4 {
 		return fmt.Errorf("Sync data %d invalid length %d, should be 4 bytes", no, len(data))
 	}
-	s.Data[no] = data
+	s.Data[no] = append([]byte(nil), data...)
 	s.updateChecksum()
 	return nil
 }
 
-func (s *Sync) validate() error {
-	if s.Checksum != s.Crc16() {
-		return fmt.Errorf("sync data checksum: %X does not match calculated checksum: %X", s.Checksum, s.Crc16())
-	}
-	return nil
-}
-
 func (s *Sync) Crc16() uint16 {
 	var data []byte
 	for _, b := range s.Data {
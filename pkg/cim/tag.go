@@ -0,0 +1,195 @@
+package cim
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// binTag is the subset of the binstruct tag syntax used by the Bin layout.
+// It is shared by the encoder and Fields so they read the layout exactly the
+// way binstruct does when decoding.
+type binTag struct {
+	ignore bool
+	le     bool    // little endian, binstruct defaults to big endian
+	length int     // len:N, bytes for scalars and strings, elements for slices
+	fn     string  // custom read function, see fieldWriters for the inverse
+	elem   *binTag // [..] tag applied to each slice element
+}
+
+func parseTag(tag string) (binTag, error) {
+	var t binTag
+	for tag != "" {
+		var v string
+		if strings.HasPrefix(tag, "[") {
+			end := strings.LastIndex(tag, "]")
+			if end == -1 {
+				return t, fmt.Errorf("unbalanced square bracket in tag %q", tag)
+			}
+			elem, err := parseTag(tag[1:end])
+			if err != nil {
+				return t, err
+			}
+			t.elem = &elem
+			tag = strings.TrimPrefix(tag[end+1:], ",")
+			continue
+		}
+		if i := strings.Index(tag, ","); i != -1 {
+			v, tag = tag[:i], tag[i+1:]
+		} else {
+			v, tag = tag, ""
+		}
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "":
+		case v == "-":
+			t.ignore = true
+		case v == "le":
+			t.le = true
+		case v == "be":
+			t.le = false
+		case strings.HasPrefix(v, "len:"):
+			n, err := strconv.Atoi(strings.TrimPrefix(v, "len:"))
+			if err != nil {
+				return t, fmt.Errorf("invalid length in tag %q: %v", v, err)
+			}
+			t.length = n
+		case strings.Contains(v, ":"):
+			return t, fmt.Errorf("unsupported tag %q", v)
+		default:
+			t.fn = v
+		}
+	}
+	return t, nil
+}
+
+// Field describes where a single leaf field of the Bin layout is stored
+type Field struct {
+	Path   []string `json:"path"`   // Struct field names from Bin down to the leaf, e.g. Keys, Checksum1
+	Offset int      `json:"offset"` // Byte offset in the dump
+	Length int      `json:"length"` // Length in bytes
+	Type   string   `json:"type"`   // Go type of the field
+	fn     string   // Custom read function, see fieldWriters
+}
+
+// Name returns the dotted path of the field, e.g. Keys.Checksum1
+func (f Field) Name() string {
+	return strings.Join(f.Path, ".")
+}
+
+// Range returns the byte range of the field
+func (f Field) Range() Range {
+	return Range{f.Offset, f.Length}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var (
+	binFields     []Field
+	binFieldsOnce sync.Once
+)
+
+// Fields returns every leaf field of the Bin layout in dump order, derived
+// from the same bin tags that are used to decode and encode a Bin.
+func Fields() []Field {
+	binFieldsOnce.Do(func() {
+		var err error
+		binFields, err = layoutFields(reflect.TypeOf(Bin{}))
+		if err != nil {
+			// The layout is static, any error here is a programming error
+			panic(err)
+		}
+	})
+	return append([]Field(nil), binFields...)
+}
+
+// FieldByName returns the field with the dotted name, e.g. Vin.Data
+func FieldByName(name string) (Field, bool) {
+	for _, f := range Fields() {
+		if f.Name() == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func layoutFields(t reflect.Type) ([]Field, error) {
+	var fields []Field
+	var offset int
+	var walk func(path []string, t reflect.Type) error
+	walk = func(path []string, t reflect.Type) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag, err := parseTag(sf.Tag.Get("bin"))
+			if err != nil {
+				return fmt.Errorf("%s: %v", sf.Name, err)
+			}
+			if tag.ignore {
+				continue
+			}
+			fieldPath := append(append([]string{}, path...), sf.Name)
+			if sf.Type.Kind() == reflect.Struct && sf.Type != timeType && tag.fn == "" {
+				start := offset
+				if err := walk(fieldPath, sf.Type); err != nil {
+					return err
+				}
+				if tag.length != 0 && offset-start != tag.length {
+					return fmt.Errorf("%s: fields add up to %d bytes, tag says %d", strings.Join(fieldPath, "."), offset-start, tag.length)
+				}
+				continue
+			}
+			length, err := tagSize(sf.Type, tag)
+			if err != nil {
+				return fmt.Errorf("%s: %v", strings.Join(fieldPath, "."), err)
+			}
+			fields = append(fields, Field{
+				Path:   fieldPath,
+				Offset: offset,
+				Length: length,
+				Type:   sf.Type.String(),
+				fn:     tag.fn,
+			})
+			offset += length
+		}
+		return nil
+	}
+	if err := walk(nil, t); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// tagSize returns the number of bytes a leaf field occupies in the dump
+func tagSize(t reflect.Type, tag binTag) (int, error) {
+	if tag.fn != "" {
+		return tag.length, nil
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		if tag.length == 0 {
+			return 1, nil
+		}
+		return tag.length, nil
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if tag.length == 0 {
+			return int(t.Size()), nil
+		}
+		return tag.length, nil
+	case reflect.String:
+		return tag.length, nil
+	case reflect.Slice:
+		elemTag := binTag{}
+		if tag.elem != nil {
+			elemTag = *tag.elem
+		}
+		n, err := tagSize(t.Elem(), elemTag)
+		if err != nil {
+			return 0, err
+		}
+		return tag.length * n, nil
+	}
+	return 0, fmt.Errorf("unsupported type %s", t)
+}
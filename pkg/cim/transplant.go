@@ -0,0 +1,122 @@
+package cim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldGroup is a set of fields that together make up part of a car's
+// identity and are moved between dumps as a whole
+type FieldGroup int
+
+const (
+	GroupVIN FieldGroup = iota
+	GroupPIN
+	GroupKeys               // Keys, ISK and the key error counter
+	GroupPSK                // PSK and sync words
+	GroupProgrammingHistory // Programming date, SPS count & workshop IDs
+)
+
+// FieldGroups lists every group in the order they are applied
+var FieldGroups = []FieldGroup{GroupVIN, GroupPIN, GroupKeys, GroupPSK, GroupProgrammingHistory}
+
+var fieldGroupNames = map[FieldGroup]string{
+	GroupVIN:                "vin",
+	GroupPIN:                "pin",
+	GroupKeys:               "keys",
+	GroupPSK:                "psk",
+	GroupProgrammingHistory: "history",
+}
+
+// fieldGroupFields are the named fields of each group, see namedFields.
+// Hardware specific fields (part numbers, serial sticker, factory date &
+// Const1) are deliberately not part of any group.
+var fieldGroupFields = map[FieldGroup][]string{
+	GroupVIN: {"vin"},
+	GroupPIN: {"pin"},
+	GroupKeys: {
+		"isk.high", "isk.low", "key_count", "key_errors",
+		"key[0]", "key[1]", "key[2]", "key[3]", "key[4]",
+	},
+	GroupPSK: {
+		"psk.low", "psk.high",
+		"sync[0]", "sync[1]", "sync[2]", "sync[3]", "sync[4]",
+	},
+	GroupProgrammingHistory: {
+		"programming_date", "sps_count",
+		"programming_id[0]", "programming_id[1]", "programming_id[2]",
+	},
+}
+
+func (g FieldGroup) String() string {
+	if n, ok := fieldGroupNames[g]; ok {
+		return n
+	}
+	return fmt.Sprintf("FieldGroup(%d)", int(g))
+}
+
+// ParseFieldGroup returns the group with the given name, see FieldGroup.String
+func ParseFieldGroup(name string) (FieldGroup, error) {
+	for g, n := range fieldGroupNames {
+		if strings.EqualFold(n, name) {
+			return g, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field group %q", name)
+}
+
+// ranges returns the byte ranges holding the group's fields
+func (g FieldGroup) ranges() []Range {
+	var ranges []Range
+	for _, name := range fieldGroupFields[g] {
+		f, ok := namedFieldByName(name)
+		if !ok {
+			panic("unknown named field " + name)
+		}
+		ranges = append(ranges, f.ranges...)
+	}
+	return ranges
+}
+
+// Transplant returns a copy of donor carrying the selected groups of car,
+// or all groups if none are given. The donor keeps its hardware specific
+// fields and every affected checksum is recalculated. donor and car are not
+// modified.
+func Transplant(donor, car *Bin, groups ...FieldGroup) (*Bin, error) {
+	if len(groups) == 0 {
+		groups = FieldGroups
+	}
+	b, err := donor.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("donor: %v", err)
+	}
+	cb, err := car.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("car: %v", err)
+	}
+
+	var touched []Range
+	for _, g := range groups {
+		if _, ok := fieldGroupFields[g]; !ok {
+			return nil, fmt.Errorf("unknown field group %d", int(g))
+		}
+		for _, r := range g.ranges() {
+			copy(b[r.Offset:r.Offset+r.Length], cb[r.Offset:r.Offset+r.Length])
+			touched = append(touched, r)
+		}
+	}
+	updateChecksums(b, touched)
+
+	out := &Bin{
+		filename: donor.filename,
+		encoding: donor.encoding,
+		layout:   donor.layout,
+		image:    donor.image,
+		format:   donor.format,
+		swapped:  donor.swapped,
+	}
+	if err := out.setBytes(b); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
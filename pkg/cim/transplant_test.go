@@ -0,0 +1,33 @@
+package cim
+
+import "testing"
+
+func TestTransplantKeys(t *testing.T) {
+	donor, err := LoadBytes("donor.bin", make([]byte, Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	car, err := LoadBytes("car.bin", make([]byte, Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := car.Keys.AddKey([]byte{0x1A, 0x2B, 0x3C, 0x4D}); err != nil {
+		t.Fatal(err)
+	}
+	car.Keys.SetErrorCount(3)
+
+	out, err := Transplant(donor, car, GroupKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Keys.Count1 != 1 || out.Keys.Count2 != 1 {
+		t.Errorf("key count = %d/%d, want 1", out.Keys.Count1, out.Keys.Count2)
+	}
+	// The error counter belongs to the keys it counted failures of
+	if out.Keys.Errors1 != 3 || out.Keys.Errors2 != 3 {
+		t.Errorf("key errors = %d/%d, want 3", out.Keys.Errors1, out.Keys.Errors2)
+	}
+	if donor.Keys.Errors1 != 0 {
+		t.Error("Transplant modified the donor")
+	}
+}
@@ -0,0 +1,265 @@
+package cim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/roffe/cim/pkg/crc16"
+)
+
+// Bank is one checksummed copy of a section
+type Bank struct {
+	No             int `json:"bank"`            // 1 or 2 for mirrored sections, 0 for single bank sections
+	Offset         int `json:"offset"`          // Offset of the data covered by the checksum
+	Length         int `json:"length"`          // Length of the data covered by the checksum
+	ChecksumOffset int `json:"checksum_offset"` // Offset of the little endian CRC16
+}
+
+// Section is a checksummed part of the dump, stored in one or two banks
+type Section struct {
+	Name  string `json:"name"`
+	Banks []Bank `json:"banks"`
+}
+
+// Mirrored reports if the section is stored in two banks
+func (s Section) Mirrored() bool {
+	return len(s.Banks) == 2
+}
+
+// sectionsAllowedBlank may have both banks zeroed, in which case the
+// checksums are not verified
+var sectionsAllowedBlank = map[string]bool{
+	"UnknownData10": true,
+}
+
+// Sections returns every checksummed section of the Bin layout. A checksum
+// covers all fields of its struct since the previous checksum.
+func Sections() []Section {
+	var sections []Section
+	var start int
+	var parent string
+	for _, f := range Fields() {
+		if len(f.Path) < 2 {
+			parent = ""
+			continue
+		}
+		if f.Path[0] != parent {
+			parent, start = f.Path[0], f.Offset
+			sections = append(sections, Section{Name: parent})
+		}
+		name := f.Path[len(f.Path)-1]
+		if !strings.HasPrefix(name, "Checksum") {
+			continue
+		}
+		s := &sections[len(sections)-1]
+		var no int
+		fmt.Sscanf(strings.TrimPrefix(name, "Checksum"), "%d", &no)
+		s.Banks = append(s.Banks, Bank{
+			No:             no,
+			Offset:         start,
+			Length:         f.Offset - start,
+			ChecksumOffset: f.Offset,
+		})
+		start = f.Offset + f.Length
+	}
+	return sections
+}
+
+// ChecksumError is reported when the stored checksum of a bank does not
+// match the checksum calculated over its data
+type ChecksumError struct {
+	Section    string `json:"section"`
+	Bank       Bank   `json:"bank"`
+	Stored     uint16 `json:"stored"`
+	Calculated uint16 `json:"calculated"`
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s%s checksum %04X at 0x%03X does not match calculated %04X over 0x%03X-0x%03X",
+		e.Section, bankName(e.Bank.No), e.Stored, e.Bank.ChecksumOffset, e.Calculated, e.Bank.Offset, e.Bank.Offset+e.Bank.Length-1)
+}
+
+// BankMismatchError is reported when the two banks of a mirrored section
+// differ, Offsets lists the differing byte offsets in bank 1
+type BankMismatchError struct {
+	Section string `json:"section"`
+	Bank1   Bank   `json:"bank1"`
+	Bank2   Bank   `json:"bank2"`
+	Offsets []int  `json:"offsets"`
+}
+
+func (e *BankMismatchError) Error() string {
+	return fmt.Sprintf("%s bank 1 (0x%03X) and bank 2 (0x%03X) differ in %d byte(s), corrupt memory?",
+		e.Section, e.Bank1.Offset, e.Bank2.Offset, len(e.Offsets))
+}
+
+func bankName(no int) string {
+	if no == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" bank %d", no)
+}
+
+// ValidationReport lists every problem found in a dump
+type ValidationReport struct {
+	Filename string  `json:"filename"`
+	Problems []error `json:"-"`
+}
+
+// OK reports if no problems where found
+func (r *ValidationReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *ValidationReport) Error() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%d validation problem(s)", len(r.Problems))
+	for _, p := range r.Problems {
+		out.WriteString("\n- " + p.Error())
+	}
+	return out.String()
+}
+
+// Is reports if any problem matches target, so errors.Is looks into the
+// report. Multi error unwrapping needs Go 1.20, the module supports 1.18.
+func (r *ValidationReport) Is(target error) bool {
+	for _, p := range r.Problems {
+		if errors.Is(p, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first problem matching target, so a caller can get at e.g.
+// the *ChecksumError of a failed Validate with errors.As
+func (r *ValidationReport) As(target interface{}) bool {
+	for _, p := range r.Problems {
+		if errors.As(p, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ValidationReport) MarshalJSON() ([]byte, error) {
+	type problem struct {
+		Type    string      `json:"type"`
+		Message string      `json:"message"`
+		Details interface{} `json:"details,omitempty"`
+	}
+	problems := make([]problem, 0, len(r.Problems))
+	for _, p := range r.Problems {
+		pr := problem{Type: "error", Message: p.Error()}
+		switch p.(type) {
+		case *ChecksumError:
+			pr.Type, pr.Details = "checksum", p
+		case *BankMismatchError:
+			pr.Type, pr.Details = "bank_mismatch", p
+		}
+		problems = append(problems, pr)
+	}
+	return json.Marshal(struct {
+		Filename string    `json:"filename"`
+		OK       bool      `json:"ok"`
+		Problems []problem `json:"problems"`
+	}{r.Filename, r.OK(), problems})
+}
+
+// Report runs every validation on the bin and returns all problems found
+func (bin *Bin) Report() *ValidationReport {
+	r := &ValidationReport{Filename: bin.filename}
+	b, err := bin.Bytes()
+	if err != nil {
+		r.Problems = append(r.Problems, err)
+		return r
+	}
+	r.Problems = validateSections(b, bin.Layout())
+	return r
+}
+
+func validateSections(b []byte, l *Layout) []error {
+	var problems []error
+	for _, s := range Sections() {
+		if !l.stores(s) {
+			continue
+		}
+		if s.Mirrored() && sectionsAllowedBlank[s.Name] &&
+			isZero(b[s.Banks[0].Offset:s.Banks[0].Offset+s.Banks[0].Length]) &&
+			isZero(b[s.Banks[1].Offset:s.Banks[1].Offset+s.Banks[1].Length]) {
+			continue
+		}
+		if s.Mirrored() {
+			if offsets := bankDiff(b, s.Banks[0], s.Banks[1]); len(offsets) > 0 {
+				problems = append(problems, &BankMismatchError{
+					Section: s.Name,
+					Bank1:   s.Banks[0],
+					Bank2:   s.Banks[1],
+					Offsets: offsets,
+				})
+			}
+		}
+		for _, bank := range s.Banks {
+			if stored, calc := bankChecksums(b, bank); stored != calc {
+				problems = append(problems, &ChecksumError{
+					Section:    s.Name,
+					Bank:       bank,
+					Stored:     stored,
+					Calculated: calc,
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// bankChecksums returns the stored and the calculated checksum of a bank
+func bankChecksums(b []byte, bank Bank) (uint16, uint16) {
+	return binary.LittleEndian.Uint16(b[bank.ChecksumOffset:]), crc16.Calc(b[bank.Offset : bank.Offset+bank.Length])
+}
+
+// updateChecksums recalculates the checksum of every bank whose data
+// overlaps one of the touched ranges
+func updateChecksums(b []byte, touched []Range) {
+	for _, s := range Sections() {
+		for _, bank := range s.Banks {
+			for _, r := range touched {
+				if r.Offset < bank.Offset+bank.Length && bank.Offset < r.Offset+r.Length {
+					binary.LittleEndian.PutUint16(b[bank.ChecksumOffset:], crc16.Calc(b[bank.Offset:bank.Offset+bank.Length]))
+					break
+				}
+			}
+		}
+	}
+}
+
+// UpdateChecksums recalculates the checksum of every bank, for dumps edited
+// outside an Editor such as hand edited JSON
+func (bin *Bin) UpdateChecksums() error {
+	b, err := bin.Bytes()
+	if err != nil {
+		return err
+	}
+	updateChecksums(b, []Range{{0, Size}})
+	return bin.setBytes(b)
+}
+
+// bankDiff returns the offsets in bank 1 where the data or checksum differs
+// from bank 2
+func bankDiff(b []byte, b1, b2 Bank) []int {
+	var offsets []int
+	for i := 0; i < b1.Length+2; i++ {
+		if b[b1.Offset+i] != b[b2.Offset+i] {
+			offsets = append(offsets, b1.Offset+i)
+		}
+	}
+	return offsets
+}
+
+func isZero(b []byte) bool {
+	return len(bytes.Trim(b, "\x00")) == 0
+}
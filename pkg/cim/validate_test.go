@@ -0,0 +1,37 @@
+package cim
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidationReportAs(t *testing.T) {
+	// Zeroed banks fail their checksums, the CRC starts at 0xFFFF
+	fw, err := LoadBytes("test.bin", make([]byte, Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fw.Validate()
+	if err == nil {
+		t.Fatal("damaged dump validated")
+	}
+
+	var report *ValidationReport
+	if !errors.As(err, &report) || report.OK() {
+		t.Fatalf("errors.As(%T, *ValidationReport) failed", err)
+	}
+	var ce *ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatal("errors.As(report, *ChecksumError) failed")
+	}
+	if ce.Stored == ce.Calculated {
+		t.Errorf("checksum error with matching checksums: %v", ce)
+	}
+	if !errors.Is(err, ce) {
+		t.Error("errors.Is(report, problem) failed")
+	}
+	var bm *BankMismatchError
+	if errors.As(err, &bm) {
+		t.Errorf("errors.As found a bank mismatch in %v", err)
+	}
+}
@@ -2,9 +2,12 @@ package cim
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/roffe/cim/pkg/crc16"
+	"github.com/roffe/cim/pkg/vin"
 )
 
 type Vin struct {
@@ -15,14 +18,6 @@ type Vin struct {
 	Checksum uint16 `bin:"le,len:2" json:"checksum"` // CRC16 MCRF4XX
 } // 30 bytes
 
-func (v *Vin) validate() error {
-	c := v.Crc16()
-	if v.Checksum != c {
-		return fmt.Errorf("vin cheksum validation failed, calculated: %X in bin: %X", c, v.Checksum)
-	}
-	return nil
-}
-
 func (v *Vin) Crc16() uint16 {
 	b := bytes.NewBuffer(nil)
 	b.Write([]byte(v.Data))
@@ -32,15 +27,34 @@ func (v *Vin) Crc16() uint16 {
 	return crc16.Calc(b.Bytes())
 }
 
-func (v *Vin) Set(vin string) error {
-	if len(vin) > 17 {
-		return fmt.Errorf("vin is to long")
+// Set validates and stores the VIN, a blank VIN is allowed for virgin CIMs
+func (v *Vin) Set(s string) error {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s != "" {
+		if err := vin.Validate(s); err != nil {
+			return err
+		}
 	}
-	v.Data = fmt.Sprintf("%-17s", vin)
+	v.Data = fmt.Sprintf("%-17s", s)
 	v.updateChecksum()
 	return nil
 }
 
+// Decode decodes the stored VIN
+func (v *Vin) Decode() (*vin.VIN, error) {
+	return vin.Decode(v.Data)
+}
+
+// MarshalJSON adds the decoded VIN, or null if it doesn't decode
+func (v Vin) MarshalJSON() ([]byte, error) {
+	type plain Vin
+	decoded, _ := v.Decode()
+	return json.Marshal(struct {
+		plain
+		Decoded *vin.VIN `json:"decoded"`
+	}{plain(v), decoded})
+}
+
 func (v *Vin) SetValue(val uint8) {
 	v.Value = val
 	v.updateChecksum()
@@ -54,3 +68,38 @@ func (v *Vin) SetSpsCount(value uint8) {
 func (v *Vin) updateChecksum() {
 	v.Checksum = v.Crc16()
 }
+
+// decodedRows returns the decoded VIN as label/value pairs for the text outputs
+func (v *Vin) decodedRows() [][2]string {
+	d, err := v.Decode()
+	if err != nil {
+		if strings.TrimSpace(v.Data) == "" {
+			return nil
+		}
+		return [][2]string{{"VIN Error", err.Error()}}
+	}
+	year := fmt.Sprint(d.ModelYear)
+	if d.Ambiguous() {
+		year += fmt.Sprintf(" (code %s, candidates %v)", d.VIN[9:10], d.ModelYearCandidates)
+	}
+	check := "valid"
+	if !d.CheckDigitValid {
+		check = fmt.Sprintf("invalid, calculated %c", vin.CheckDigit(d.VIN))
+	}
+	manufacturer := d.Manufacturer
+	if manufacturer == "" {
+		manufacturer = "unknown"
+	}
+	return [][2]string{
+		{"WMI", fmt.Sprintf("%s (%s)", manufacturer, d.WMI)},
+		{"Model Line", d.ModelLine.String()},
+		{"Series", d.Series},
+		{"Body Style", d.BodyStyle.String()},
+		{"Restraint System", d.Restraint.String()},
+		{"Engine", d.Engine.String()},
+		{"Check Digit", fmt.Sprintf("%s (%s)", d.CheckDigit, check)},
+		{"Model Year", year},
+		{"Plant", d.Plant.String()},
+		{"Serial", d.Serial},
+	}
+}
@@ -0,0 +1,38 @@
+package cim
+
+import "testing"
+
+func TestVinSet(t *testing.T) {
+	var v Vin
+	v.Unknown = make([]byte, 9)
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"YS3FD49Y691012345", "YS3FD49Y691012345"},
+		{" ys3fd49y691012345\n", "YS3FD49Y691012345"},
+		// Blank for virgin CIMs
+		{"", "                 "},
+	} {
+		if err := v.Set(tc.in); err != nil {
+			t.Errorf("Set(%q): %v", tc.in, err)
+			continue
+		}
+		if v.Data != tc.want {
+			t.Errorf("Set(%q) stored %q, want %q", tc.in, v.Data, tc.want)
+		}
+		if v.Checksum != v.Crc16() {
+			t.Errorf("Set(%q) left checksum %04X, calculated %04X", tc.in, v.Checksum, v.Crc16())
+		}
+	}
+
+	v.Set("YS3FD49Y691012345")
+	for _, in := range []string{"YS3FD49Y69101234", "YS3FD49Y6I1012345", "YS3FD49Y6U1012345", "YS3FD49Y691012345X"} {
+		if err := v.Set(in); err == nil {
+			t.Errorf("Set(%q) accepted an invalid vin", in)
+		}
+		if v.Data != "YS3FD49Y691012345" {
+			t.Errorf("Set(%q) changed the vin to %q on error", in, v.Data)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package cim
+
+import "strings"
+
+// IsVirgin reports if the CIM has never been married to a car: blank VIN,
+// zeroed ISK, no programmed keys and no SPS programming.
+func (bin *Bin) IsVirgin() bool {
+	zeroIsk := isZero(bin.Keys.IskHI1) && isZero(bin.Keys.IskLO1) &&
+		isZero(bin.Keys.IskHI2) && isZero(bin.Keys.IskLO2)
+	return strings.Trim(bin.Vin.Data, " \x00\xff") == "" &&
+		zeroIsk &&
+		bin.Keys.Count1 == 0 && bin.Keys.Count2 == 0 &&
+		bin.Vin.SpsCount == 0
+}
+
+// Virginize returns the CIM to a factory like, unmarried state. The VIN is
+// blanked, ISK, keys and key errors are zeroed and the SPS counter reset.
+// Hardware identity (part numbers, serial sticker, factory date & Const1)
+// and everything else is kept as is.
+func (bin *Bin) Virginize() error {
+	e, err := bin.Edit()
+	if err != nil {
+		return err
+	}
+	if err := e.Vin.Set(""); err != nil {
+		return err
+	}
+	e.Vin.SetSpsCount(0)
+
+	if err := e.Keys.SetIsk(make([]byte, 4), make([]byte, 2)); err != nil {
+		return err
+	}
+	if err := e.Keys.SetKeyCount(0); err != nil {
+		return err
+	}
+	e.Keys.ClearUnusedSlots()
+	if err := e.Keys.SetErrorCount(0); err != nil {
+		return err
+	}
+	_, err = e.Commit()
+	return err
+}
@@ -0,0 +1,71 @@
+package cim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVirginize(t *testing.T) {
+	fw, err := LoadBytes("test.bin", testDump(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Keys.SetIsk([]byte{1, 2, 3, 4}, []byte{5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range [][]byte{{1, 1, 1, 1}, {2, 2, 2, 2}} {
+		if _, err := fw.Keys.AddKey(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Keys.SetErrorCount(2); err != nil {
+		t.Fatal(err)
+	}
+	fw.Vin.SetSpsCount(3)
+	married, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := append([]byte(nil), married...)
+	bin, err := LoadBytes("test.bin", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin.IsVirgin() {
+		t.Fatal("married dump reported virgin")
+	}
+	if err := bin.Virginize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bin.IsVirgin() {
+		t.Errorf("virginized dump not virgin")
+	}
+	if err := bin.Validate(); err != nil {
+		t.Errorf("virginized dump doesn't validate: %v", err)
+	}
+	if bin.Keys.Errors1 != 0 || bin.Keys.Errors2 != 0 {
+		t.Errorf("key errors %d/%d, want 0", bin.Keys.Errors1, bin.Keys.Errors2)
+	}
+	for i := 0; i < MaxKeys; i++ {
+		if !isZero(bin.Keys.Data1[i]) || !isZero(bin.Keys.Data2[i]) {
+			t.Errorf("key slot %d not cleared", i)
+		}
+	}
+	if bin.PartNo1 != fw.PartNo1 {
+		t.Errorf("PartNo1 %d, want it kept as %d", bin.PartNo1, fw.PartNo1)
+	}
+	if !bytes.Equal(src, married) {
+		t.Errorf("source bytes modified")
+	}
+
+	// Loading the source again still gives the married dump
+	again, err := LoadBytes("test.bin", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.IsVirgin() {
+		t.Errorf("source dump virginized")
+	}
+}
@@ -0,0 +1,241 @@
+// Package corpus analyses a collection of dumps byte by byte to help telling
+// constants, counters and per vehicle secrets apart in the unknown regions.
+package corpus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+// Known fields every byte is correlated with
+var knownFields = []struct {
+	name  string
+	value func(*cim.Bin) (float64, bool)
+}{
+	{"model_year", func(b *cim.Bin) (float64, bool) {
+		y := b.ModelYear()
+		return float64(y), y != 0
+	}},
+	{"partno", func(b *cim.Bin) (float64, bool) { return float64(b.PartNo), true }},
+	{"key_count", func(b *cim.Bin) (float64, bool) { return float64(b.Keys.Count1), true }},
+	{"sps_count", func(b *cim.Bin) (float64, bool) { return float64(b.Vin.SpsCount), true }},
+}
+
+// ByteStats are the statistics of one byte offset over the corpus
+type ByteStats struct {
+	Offset   int     `json:"offset"`
+	Min      byte    `json:"min"`
+	Max      byte    `json:"max"`
+	Distinct int     `json:"distinct"`
+	Entropy  float64 `json:"entropy"` // Shannon entropy in bits, 0-8
+	// Pearson correlation with the known fields, fields without variance
+	// over the corpus are left out
+	Correlations map[string]float64 `json:"correlations,omitempty"`
+	Hint         string             `json:"hint"`
+}
+
+// Group is a checksummed section, or a field outside the sections, and the
+// statistics of its bytes
+type Group struct {
+	Name   string      `json:"name"`
+	Offset int         `json:"offset"`
+	Length int         `json:"length"`
+	Bytes  []ByteStats `json:"bytes"`
+}
+
+// Report is the result of analysing a corpus
+type Report struct {
+	Dumps  int      `json:"dumps"`
+	Groups []Group  `json:"groups"`
+	Errors []string `json:"errors,omitempty"` // files that failed to load
+}
+
+// LoadDir loads every file in dir as a dump. Files that fail to load are
+// returned as errors and don't stop the others from loading.
+func LoadDir(dir string) ([]*cim.Bin, []error, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bins []*cim.Bin
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		bin, err := cim.Load(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		bins = append(bins, bin)
+	}
+	return bins, errs, nil
+}
+
+// Analyze returns the per byte statistics of bins grouped by the checksummed
+// sections, bytes outside them are grouped by their top level field
+func Analyze(bins []*cim.Bin) (*Report, error) {
+	data := make([][]byte, len(bins))
+	for i, bin := range bins {
+		b, err := bin.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", bin.Filename(), err)
+		}
+		data[i] = b
+	}
+
+	known := make(map[string][]float64)
+	present := make(map[string][]bool)
+	for _, kf := range knownFields {
+		for _, bin := range bins {
+			v, ok := kf.value(bin)
+			known[kf.name] = append(known[kf.name], v)
+			present[kf.name] = append(present[kf.name], ok)
+		}
+	}
+
+	r := &Report{Dumps: len(bins)}
+	for _, g := range groups() {
+		for off := g.Offset; off < g.Offset+g.Length; off++ {
+			g.Bytes = append(g.Bytes, byteStats(data, off, known, present))
+		}
+		r.Groups = append(r.Groups, g)
+	}
+	return r, nil
+}
+
+// groups returns the sections and the top level fields between them in
+// layout order
+func groups() []Group {
+	sections := make(map[string]cim.Section)
+	for _, s := range cim.Sections() {
+		sections[s.Name] = s
+	}
+	var out []Group
+	for _, f := range cim.Fields() {
+		name := f.Path[0]
+		if n := len(out); n > 0 && out[n-1].Name == name {
+			continue
+		}
+		g := Group{Name: name, Offset: f.Offset}
+		if s, ok := sections[name]; ok {
+			last := s.Banks[len(s.Banks)-1]
+			g.Offset, g.Length = s.Banks[0].Offset, last.ChecksumOffset+2-s.Banks[0].Offset
+		} else {
+			for _, ff := range cim.Fields() {
+				if ff.Path[0] == name {
+					g.Length = ff.Offset + ff.Length - g.Offset
+				}
+			}
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+func byteStats(data [][]byte, off int, known map[string][]float64, present map[string][]bool) ByteStats {
+	s := ByteStats{Offset: off, Min: 0xFF}
+	var counts [256]int
+	values := make([]float64, len(data))
+	for i, b := range data {
+		v := b[off]
+		counts[v]++
+		values[i] = float64(v)
+		if v < s.Min {
+			s.Min = v
+		}
+		if v > s.Max {
+			s.Max = v
+		}
+	}
+	if len(data) == 0 {
+		s.Min = 0
+	}
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		s.Distinct++
+		p := float64(c) / float64(len(data))
+		s.Entropy -= p * math.Log2(p)
+	}
+
+	for _, kf := range knownFields {
+		var xs, ys []float64
+		for i, ok := range present[kf.name] {
+			if ok {
+				xs = append(xs, values[i])
+				ys = append(ys, known[kf.name][i])
+			}
+		}
+		if c, ok := pearson(xs, ys); ok {
+			if s.Correlations == nil {
+				s.Correlations = make(map[string]float64)
+			}
+			s.Correlations[kf.name] = c
+		}
+	}
+	s.Hint = hint(s, len(data))
+	return s
+}
+
+// hint guesses what kind of value the byte holds
+func hint(s ByteStats, n int) string {
+	switch {
+	case n < 2:
+		return ""
+	case s.Distinct == 1:
+		return "constant"
+	case math.Abs(s.Correlations["sps_count"]) > 0.9 || math.Abs(s.Correlations["key_count"]) > 0.9:
+		return "counter"
+	case s.Distinct == n && n >= 4:
+		return "per vehicle"
+	}
+	for _, name := range sortedKeys(s.Correlations) {
+		if math.Abs(s.Correlations[name]) > 0.9 {
+			return "follows " + name
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pearson returns the correlation coefficient of xs and ys, false if either
+// has no variance
+func pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0, false
+	}
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	mx, my := sx/n, sy/n
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(vx*vy), true
+}
@@ -0,0 +1,93 @@
+package corpus
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+func TestAnalyze(t *testing.T) {
+	sps, _ := cim.FieldByName("Vin.SpsCount")
+	var unknown cim.Section
+	for _, s := range cim.Sections() {
+		if s.Name == "UnknownData5" {
+			unknown = s
+		}
+	}
+	secret := unknown.Banks[0].Offset
+
+	dir := t.TempDir()
+	for i, v := range []byte{7, 200, 3, 90} {
+		b := make([]byte, cim.Size)
+		b[0] = 0x20
+		b[sps.Offset] = byte(i + 1)
+		b[secret] = v
+		if err := ioutil.WriteFile(filepath.Join(dir, string(rune('a'+i))+".bin"), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a dump"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bins, errs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 4 || len(errs) != 1 {
+		t.Fatalf("loaded %d dumps with %d errors, want 4 and 1", len(bins), len(errs))
+	}
+	r, err := Analyze(bins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Dumps != 4 {
+		t.Errorf("Dumps = %d, want 4", r.Dumps)
+	}
+
+	// Groups follow the sections and cover every byte once
+	sections := make(map[string]cim.Section)
+	for _, s := range cim.Sections() {
+		sections[s.Name] = s
+	}
+	stats := make(map[int]ByteStats)
+	var next int
+	for _, g := range r.Groups {
+		if g.Offset != next {
+			t.Fatalf("group %s starts at 0x%03X, want 0x%03X", g.Name, g.Offset, next)
+		}
+		next = g.Offset + g.Length
+		if s, ok := sections[g.Name]; ok {
+			last := s.Banks[len(s.Banks)-1]
+			if g.Offset != s.Banks[0].Offset || next != last.ChecksumOffset+2 {
+				t.Errorf("group %s is 0x%03X-0x%03X, not the section bounds", g.Name, g.Offset, next-1)
+			}
+			delete(sections, g.Name)
+		}
+		if len(g.Bytes) != g.Length {
+			t.Errorf("group %s has %d byte stats, want %d", g.Name, len(g.Bytes), g.Length)
+		}
+		for _, b := range g.Bytes {
+			stats[b.Offset] = b
+		}
+	}
+	if next != cim.Size {
+		t.Errorf("groups end at 0x%03X, want 0x%03X", next, cim.Size)
+	}
+	for name := range sections {
+		t.Errorf("section %s has no group", name)
+	}
+
+	if s := stats[0]; s.Hint != "constant" || s.Min != 0x20 || s.Max != 0x20 || s.Entropy != 0 {
+		t.Errorf("magic byte %+v, want a 0x20 constant", s)
+	}
+	if s := stats[sps.Offset]; s.Hint != "counter" || s.Distinct != 4 || math.Abs(s.Correlations["sps_count"]-1) > 1e-9 {
+		t.Errorf("sps count %+v, want a counter", s)
+	}
+	if s := stats[secret]; s.Hint != "per vehicle" || s.Min != 3 || s.Max != 200 || s.Entropy != 2 {
+		t.Errorf("secret %+v, want per vehicle", s)
+	}
+}
@@ -0,0 +1,77 @@
+package crc16
+
+import "testing"
+
+func TestCatalogueCheck(t *testing.T) {
+	for _, params := range Catalogue {
+		t.Run(params.Name, func(t *testing.T) {
+			if got := Checksum([]byte("123456789"), MakeTable(params)); got != params.Check {
+				t.Errorf("Checksum(\"123456789\") = 0x%04X, want 0x%04X", got, params.Check)
+			}
+		})
+	}
+}
+
+func TestCalc(t *testing.T) {
+	if got := Calc([]byte("123456789")); got != CRC16_MCRF4XX.Check {
+		t.Errorf("Calc(\"123456789\") = 0x%04X, want 0x%04X", got, CRC16_MCRF4XX.Check)
+	}
+}
+
+func TestHash(t *testing.T) {
+	for _, params := range append(Catalogue, Params{0x1021, 0xFFFF, true, false, 0x0000, 0, "refin-only"}, Params{0x8005, 0x0000, false, true, 0xFFFF, 0, "refout-only"}) {
+		t.Run(params.Name, func(t *testing.T) {
+			table := MakeTable(params)
+			data := []byte("123456789")
+			want := Complete(Update(Init(table), data, table), table)
+
+			h := New(table)
+			h.Write(data[:4])
+			h.Write(data[4:])
+			if got := h.Sum16(); got != want {
+				t.Errorf("Sum16() = 0x%04X, want 0x%04X", got, want)
+			}
+			if got := h.Sum([]byte{0xAA}); len(got) != 3 || got[0] != 0xAA || uint16(got[1])<<8|uint16(got[2]) != want {
+				t.Errorf("Sum() = %X, want AA%04X", got, want)
+			}
+
+			h.Reset()
+			h.Write(data)
+			if got := h.Sum16(); got != want {
+				t.Errorf("Sum16() after Reset = 0x%04X, want 0x%04X", got, want)
+			}
+		})
+	}
+}
+
+var benchData = make([]byte, 512)
+
+func BenchmarkUpdate(b *testing.B) {
+	table := MakeTable(CRC16_MCRF4XX)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		Complete(Update(Init(table), benchData, table), table)
+	}
+}
+
+func BenchmarkHash(b *testing.B) {
+	table := MakeTable(CRC16_MCRF4XX)
+	h := New(table)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(benchData)
+		h.Sum16()
+	}
+}
+
+func BenchmarkHashUnreflected(b *testing.B) {
+	table := MakeTable(CRC16_XMODEM)
+	h := New(table)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(benchData)
+		h.Sum16()
+	}
+}
@@ -0,0 +1,68 @@
+package crc16
+
+import "hash"
+
+// Size of a CRC-16 checksum in bytes.
+const Size = 2
+
+// Hash16 is the common interface implemented by all 16-bit hash functions.
+type Hash16 interface {
+	hash.Hash
+	Sum16() uint16
+}
+
+// digest keeps the register of reflected algorithms reflected, so Write
+// needs no per byte bit reversal
+type digest struct {
+	crc   uint16
+	table *Table
+}
+
+// New creates a new Hash16 computing the CRC-16 checksum using the
+// algorithm represented by the Table.
+func New(table *Table) Hash16 {
+	d := &digest{table: table}
+	d.Reset()
+	return d
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return 1 }
+
+func (d *digest) Reset() {
+	d.crc = d.table.params.Init
+	if d.table.params.RefIn {
+		d.crc = ReverseUint16(d.crc)
+	}
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	crc := d.crc
+	if d.table.params.RefIn {
+		for _, b := range p {
+			crc = crc>>8 ^ d.table.reflected[byte(crc)^b]
+		}
+	} else {
+		for _, b := range p {
+			crc = crc<<8 ^ d.table.data[byte(crc>>8)^b]
+		}
+	}
+	d.crc = crc
+	return len(p), nil
+}
+
+func (d *digest) Sum16() uint16 {
+	crc := d.crc
+	// crc is reflected when RefIn, reflect it once more if RefOut differs
+	if d.table.params.RefIn != d.table.params.RefOut {
+		crc = ReverseUint16(crc)
+	}
+	return crc ^ d.table.params.XorOut
+}
+
+// Sum appends the big-endian checksum to in.
+func (d *digest) Sum(in []byte) []byte {
+	s := d.Sum16()
+	return append(in, byte(s>>8), byte(s))
+}
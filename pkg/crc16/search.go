@@ -0,0 +1,138 @@
+package crc16
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Catalogue lists every predefined algorithm
+var Catalogue = []Params{
+	CRC16_ARC, CRC16_AUG_CCITT, CRC16_BUYPASS, CRC16_CCITT_FALSE, CRC16_CDMA2000,
+	CRC16_DDS_110, CRC16_DECT_R, CRC16_DECT_X, CRC16_DNP, CRC16_EN_13757,
+	CRC16_GENIBUS, CRC16_MAXIM, CRC16_MCRF4XX, CRC16_RIELLO, CRC16_T10_DIF,
+	CRC16_TELEDISK, CRC16_TMS37157, CRC16_USB, CRC16_CRC_A, CRC16_KERMIT,
+	CRC16_MODBUS, CRC16_X_25, CRC16_XMODEM,
+}
+
+// Match is an algorithm and data range that reproduces a stored checksum
+type Match struct {
+	Algorithm    string `json:"algorithm"`
+	Start        int    `json:"start"` // First byte covered
+	End          int    `json:"end"`   // One past the last byte covered
+	LittleEndian bool   `json:"little_endian"`
+}
+
+func (m Match) String() string {
+	order := "be"
+	if m.LittleEndian {
+		order = "le"
+	}
+	return fmt.Sprintf("%s 0x%03X-0x%03X (%d bytes) %s", m.Algorithm, m.Start, m.End-1, m.End-m.Start, order)
+}
+
+// SearchOptions limits a search
+type SearchOptions struct {
+	ChecksumOffset int // Offset of the 2 byte stored checksum
+	From, To       int // Window the covered range must lie in, [From, To)
+	MinLength      int // Shortest range tried, short ranges match by chance
+}
+
+func (o SearchOptions) check(size int) error {
+	if o.ChecksumOffset < 0 || o.ChecksumOffset+2 > size {
+		return fmt.Errorf("checksum offset 0x%X outside the %d byte data", o.ChecksumOffset, size)
+	}
+	if o.From < 0 || o.To > size || o.From >= o.To {
+		return fmt.Errorf("invalid search window 0x%X-0x%X for %d byte data", o.From, o.To, size)
+	}
+	return nil
+}
+
+// Search tries every catalogue algorithm on every range in the window and
+// returns the combinations reproducing the checksum stored at
+// ChecksumOffset, in either byte order. Ranges covering the checksum itself
+// are skipped.
+func Search(data []byte, opts SearchOptions) ([]Match, error) {
+	if err := opts.check(len(data)); err != nil {
+		return nil, err
+	}
+	storedLE := binary.LittleEndian.Uint16(data[opts.ChecksumOffset:])
+	storedBE := binary.BigEndian.Uint16(data[opts.ChecksumOffset:])
+	minLength := opts.MinLength
+	if minLength < 1 {
+		minLength = 1
+	}
+
+	var matches []Match
+	for _, params := range Catalogue {
+		h := New(MakeTable(params))
+		for start := opts.From; start < opts.To; start++ {
+			h.Reset()
+			for end := start + 1; end <= opts.To; end++ {
+				// Growing past the checksum would cover it
+				if end > opts.ChecksumOffset && start < opts.ChecksumOffset+2 {
+					break
+				}
+				h.Write(data[end-1 : end])
+				if end-start < minLength {
+					continue
+				}
+				sum := h.Sum16()
+				if sum == storedLE {
+					matches = append(matches, Match{params.Name, start, end, true})
+				}
+				// Symmetric values match both orders, report them once
+				if sum == storedBE && storedBE != storedLE {
+					matches = append(matches, Match{params.Name, start, end, false})
+				}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// RankedMatch is a Match and the number of dumps it held for
+type RankedMatch struct {
+	Match
+	Hits int `json:"hits"`
+}
+
+// SearchCorpus runs Search on every dump and ranks the combinations by the
+// number of dumps they reproduce the checksum of. Ties are ranked by the
+// range ending closest to the checksum, then by the longest range.
+func SearchCorpus(dumps [][]byte, opts SearchOptions) ([]RankedMatch, error) {
+	hits := make(map[Match]int)
+	for i, data := range dumps {
+		matches, err := Search(data, opts)
+		if err != nil {
+			return nil, fmt.Errorf("dump %d: %v", i, err)
+		}
+		for _, m := range matches {
+			hits[m]++
+		}
+	}
+
+	ranked := make([]RankedMatch, 0, len(hits))
+	for m, n := range hits {
+		ranked = append(ranked, RankedMatch{m, n})
+	}
+	distance := func(m Match) int {
+		if d := opts.ChecksumOffset - m.End; d >= 0 {
+			return d
+		}
+		return m.Start - opts.ChecksumOffset - 2
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		switch {
+		case a.Hits != b.Hits:
+			return a.Hits > b.Hits
+		case distance(a.Match) != distance(b.Match):
+			return distance(a.Match) < distance(b.Match)
+		case a.End-a.Start != b.End-b.Start:
+			return a.End-a.Start > b.End-b.Start
+		}
+		return a.String() < b.String()
+	})
+	return ranked, nil
+}
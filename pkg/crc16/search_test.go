@@ -0,0 +1,105 @@
+package crc16
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// samples returns n random dumps with a MCRF4XX checksum of data[8:40]
+// stored little endian at 40
+func samples(r *rand.Rand, n int) [][]byte {
+	table := MakeTable(CRC16_MCRF4XX)
+	dumps := make([][]byte, n)
+	for i := range dumps {
+		b := make([]byte, 64)
+		r.Read(b)
+		binary.LittleEndian.PutUint16(b[40:], Checksum(b[8:40], table))
+		dumps[i] = b
+	}
+	return dumps
+}
+
+func TestSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	opts := SearchOptions{ChecksumOffset: 40, From: 0, To: 64, MinLength: 8}
+	want := Match{CRC16_MCRF4XX.Name, 8, 40, true}
+
+	for i, data := range samples(r, 4) {
+		matches, err := Search(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, m := range matches {
+			if m == want {
+				found = true
+			}
+			if m.Start < 40 && m.End > 40 {
+				t.Errorf("sample %d: %v covers the checksum", i, m)
+			}
+		}
+		if !found {
+			t.Errorf("sample %d: %v not in %v", i, want, matches)
+		}
+	}
+
+	// Random data has nothing to find when few ranges are tried
+	data := make([]byte, 24)
+	r.Read(data)
+	matches, err := Search(data, SearchOptions{ChecksumOffset: 22, From: 0, To: 22, MinLength: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("random data matched %v", matches)
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	data := make([]byte, 64)
+	for _, opts := range []SearchOptions{
+		{ChecksumOffset: -1, From: 0, To: 64},
+		{ChecksumOffset: 63, From: 0, To: 64},
+		{ChecksumOffset: 40, From: -1, To: 64},
+		{ChecksumOffset: 40, From: 0, To: 65},
+		{ChecksumOffset: 40, From: 10, To: 10},
+	} {
+		if _, err := Search(data, opts); err == nil {
+			t.Errorf("%+v: no error", opts)
+		}
+	}
+}
+
+func TestSearchCorpus(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	opts := SearchOptions{ChecksumOffset: 40, From: 0, To: 64, MinLength: 8}
+
+	ranked, err := SearchCorpus(samples(r, 6), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RankedMatch{Match{CRC16_MCRF4XX.Name, 8, 40, true}, 6}
+	if len(ranked) == 0 || ranked[0] != want {
+		t.Fatalf("ranked %v, want %v first", ranked, want)
+	}
+	if len(ranked) > 1 && ranked[1].Hits == want.Hits {
+		t.Errorf("%v ties with %v", ranked[1], want)
+	}
+
+	// Chance matches on random data never hold for more than one dump
+	random := make([][]byte, 6)
+	for i := range random {
+		random[i] = make([]byte, 64)
+		r.Read(random[i])
+	}
+	ranked, err = SearchCorpus(random, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ranked {
+		if m.Hits > 1 {
+			t.Errorf("random data: %v", m)
+		}
+	}
+}
@@ -1,114 +1,138 @@
+// Package jesus shows every way a run of bytes can be read, to help figuring
+// out what an unknown field holds.
 package jesus
 
 import (
-	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
-	"log"
-	"reflect"
+	"strings"
+	"time"
+
+	"github.com/roffe/cim/pkg/cim"
 )
 
-func Dump(v interface{}) {
-	fmt.Println(v)
-	fmt.Printf("%X\n", v)
-	fmt.Printf("%q\n", v)
-	reverseSlice(v)
-	fmt.Println(v)
-	fmt.Printf("%X\n", v)
-	fmt.Printf("%q\n", v)
-	reverseSlice(v)
-
-	switch t := v.(type) {
-	case []byte:
-		var u16le uint16
-		if err := binary.Read(bytes.NewReader(t), binary.LittleEndian, &u16le); err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println("u16le", u16le)
-		var u16be uint16
-		if err := binary.Read(bytes.NewReader(t), binary.BigEndian, &u16be); err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println("u16be", u16be)
+// Interpretation is one reading of the inspected bytes
+type Interpretation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
 
-		if len(t) == 3 {
-			t = append(t, 0x00)
-		}
+// Inspect returns every interpretation of b. It is safe on any length,
+// readings that need more bytes than given are left out.
+func Inspect(b []byte) []Interpretation {
+	var out []Interpretation
+	add := func(name, format string, a ...interface{}) {
+		out = append(out, Interpretation{name, fmt.Sprintf(format, a...)})
+	}
+
+	add("hex", "%X", b)
+	add("ascii", "%s", printable(b))
+	add("bits", "%s", bits(b))
 
-		var u32le uint32
-		if err := binary.Read(bytes.NewReader(t), binary.LittleEndian, &u32le); err != nil {
-			log.Fatal(err)
+	for _, n := range []int{1, 2, 4, 8} {
+		if len(b) < n {
+			break
 		}
-		fmt.Println("u32le", u32le)
-		var u32be uint32
-		if err := binary.Read(bytes.NewReader(t), binary.BigEndian, &u32be); err != nil {
-			log.Fatal(err)
+		if n == 1 {
+			add("u8", "%d", b[0])
+			add("i8", "%d", int8(b[0]))
+			continue
 		}
-		fmt.Println("u32be", u32be)
-
-		if len(t) == 5 {
-			b := append([]byte{0x00, 0x00, 0x00, 0x00}, t...)
-			var u64le uint64
-			if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &u64le); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("5u64le", u64le)
-			var u64be uint64
-			if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &u64be); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("5u64be", u64be)
-
-			b2 := append(t, []byte{0x00, 0x00, 0x00, 0x00}...)
-			var u64le2 uint64
-			if err := binary.Read(bytes.NewReader(b2), binary.LittleEndian, &u64le2); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("5u64leb", u64le)
-			var u64be2 uint64
-			if err := binary.Read(bytes.NewReader(b2), binary.BigEndian, &u64be2); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("5u64beb", u64be)
-
-		} else {
-			var u64le uint64
-			if err := binary.Read(bytes.NewReader(t), binary.LittleEndian, &u64le); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("u64le", u64le)
-			var u64be uint64
-			if err := binary.Read(bytes.NewReader(t), binary.BigEndian, &u64be); err != nil {
-				log.Fatal(err)
-			}
-			fmt.Println("u64be", u64be)
+		add(fmt.Sprintf("u%dle", n*8), "%d", uintLE(b[:n]))
+		add(fmt.Sprintf("u%dbe", n*8), "%d", uintBE(b[:n]))
+	}
+	// Odd sizes such as the 3 byte dates and 5 byte serial as a whole
+	if n := len(b); n == 3 || n == 5 || n == 6 || n == 7 {
+		add(fmt.Sprintf("u%dle", n*8), "%d", uintLE(b))
+		add(fmt.Sprintf("u%dbe", n*8), "%d", uintBE(b))
+	}
+
+	if len(b) > 0 {
+		add("bcd", "%s", bcd(b))
+	}
+	if len(b) >= 3 {
+		add("bcd date yy-mm-dd", "%s", bcdDate("06-01-02", b[:3]))
+		add("bcd date dd-mm-yy", "%s", bcdDate("02-01-06", b[:3]))
+	}
+
+	x := make([]byte, len(b))
+	for i, c := range b {
+		x[i] = c ^ 0xFF
+	}
+	add("xor ff", "%X", x)
+	add("xor ff ascii", "%s", printable(x))
+
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	add("reversed", "%X", r)
+	add("reversed ascii", "%s", printable(r))
+	return out
+}
+
+// InspectBin returns every interpretation of length bytes at offset in bin
+func InspectBin(bin *cim.Bin, offset, length int) ([]Interpretation, error) {
+	b, err := bin.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || length < 0 || offset+length > len(b) {
+		return nil, fmt.Errorf("0x%X+%d is outside the %d byte dump", offset, length, len(b))
+	}
+	return Inspect(b[offset : offset+length]), nil
+}
+
+// Dump prints every interpretation of b
+func Dump(b []byte) {
+	for _, i := range Inspect(b) {
+		fmt.Printf("%-18s %s\n", i.Name, i.Value)
+	}
+}
+
+func uintLE(b []byte) uint64 {
+	var buf [8]byte
+	copy(buf[:], b)
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func uintBE(b []byte) uint64 {
+	var buf [8]byte
+	copy(buf[8-len(b):], b)
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+func printable(b []byte) string {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if c < 0x20 || c > 0x7E {
+			c = '.'
 		}
-		/*
-			for i := byte(0x00); i < 0xff; i++ {
-				var os []byte
-				for _, bbb := range t {
-					os = append(os, bbb^i)
-				}
-				fmt.Printf("%d %X\n", i, os)
-				//fmt.Printf("%s\n", os)
-				//fmt.Printf("%d %d\n", i, os)
-
-			}
-		*/
+		out[i] = c
+	}
+	return string(out)
+}
+
+func bits(b []byte) string {
+	out := make([]string, len(b))
+	for i, c := range b {
+		out[i] = fmt.Sprintf("%08b", c)
 	}
+	return strings.Join(out, " ")
 }
 
-func reverseSlice(data interface{}) {
-	value := reflect.ValueOf(data)
-	if value.Kind() != reflect.Slice {
-		panic(errors.New("data must be a slice type"))
+func bcd(b []byte) string {
+	s := fmt.Sprintf("%X", b)
+	if strings.Trim(s, "0123456789") != "" {
+		return "invalid"
 	}
-	valueLen := value.Len()
-	for i := 0; i <= int((valueLen-1)/2); i++ {
-		reverseIndex := valueLen - 1 - i
-		tmp := value.Index(reverseIndex).Interface()
-		value.Index(reverseIndex).Set(value.Index(i))
-		value.Index(i).Set(reflect.ValueOf(tmp))
+	return s
+}
+
+func bcdDate(format string, b []byte) string {
+	t, err := time.Parse(format, fmt.Sprintf("%02X-%02X-%02X", b[0], b[1], b[2]))
+	if err != nil {
+		return "invalid"
 	}
+	return t.Format(cim.IsoDate)
 }
@@ -0,0 +1,99 @@
+package jesus
+
+import (
+	"testing"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     []byte
+		want   map[string]string
+		absent []string
+	}{
+		{
+			name:   "empty",
+			in:     nil,
+			want:   map[string]string{"hex": "", "ascii": "", "xor ff": "", "reversed": ""},
+			absent: []string{"u8", "u16le", "bcd", "bcd date yy-mm-dd"},
+		},
+		{
+			name: "one byte",
+			in:   []byte{0xFF},
+			want: map[string]string{
+				"hex": "FF", "ascii": ".", "bits": "11111111", "u8": "255", "i8": "-1",
+				"bcd": "invalid", "xor ff": "00", "reversed": "FF",
+			},
+			absent: []string{"u16le", "u16be", "bcd date yy-mm-dd"},
+		},
+		{
+			name: "date",
+			in:   []byte{0x20, 0x12, 0x31},
+			want: map[string]string{
+				"ascii": " .1", "u8": "32", "u16le": "4640", "u16be": "8210",
+				"u24le": "3215904", "u24be": "2101809", "bcd": "201231",
+				"bcd date yy-mm-dd": "2020-12-31", "bcd date dd-mm-yy": "2031-12-20",
+				"xor ff": "DFEDCE", "reversed": "311220",
+			},
+			absent: []string{"u32le"},
+		},
+		{
+			name: "nine bytes",
+			in:   []byte{1, 2, 3, 4, 5, 6, 7, 8, 9},
+			want: map[string]string{
+				"u32be": "16909060", "u64le": "578437695752307201", "u64be": "72623859790382856",
+				"bcd": "010203040506070809", "bcd date yy-mm-dd": "2001-02-03", "reversed": "090807060504030201",
+			},
+			absent: []string{"u72le", "u72be"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, i := range Inspect(tt.in) {
+				got[i.Name] = i.Value
+			}
+			for name, want := range tt.want {
+				if v, ok := got[name]; !ok || v != want {
+					t.Errorf("%s = %q, want %q", name, v, want)
+				}
+			}
+			for _, name := range tt.absent {
+				if v, ok := got[name]; ok {
+					t.Errorf("%s = %q, want it left out", name, v)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectBin(t *testing.T) {
+	b := make([]byte, cim.Size)
+	b[0], b[1] = 0x20, 0x12
+	bin, err := cim.LoadBytes("test.bin", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		offset, length int
+		ok             bool
+	}{
+		{0, 2, true},
+		{cim.Size - 1, 1, true},
+		{cim.Size, 0, true},
+		{-1, 2, false},
+		{0, -1, false},
+		{cim.Size - 1, 2, false},
+		{cim.Size, 1, false},
+	} {
+		out, err := InspectBin(bin, tc.offset, tc.length)
+		if (err == nil) != tc.ok {
+			t.Errorf("InspectBin(%d, %d) error %v, want ok %v", tc.offset, tc.length, err, tc.ok)
+		}
+		if tc.ok && tc.offset == 0 && out[0].Value != "2012" {
+			t.Errorf("InspectBin(0, 2) hex = %s, want 2012", out[0].Value)
+		}
+	}
+}
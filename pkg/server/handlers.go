@@ -4,14 +4,17 @@ import (
 	"bytes"
 	_ "embed"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roffe/cim/pkg/cim"
+	"github.com/roffe/cim/pkg/jesus"
 )
 
 // embed favicon.ico
@@ -28,30 +31,32 @@ func faviconHandler(c *gin.Context) {
 
 // Handle file uploads
 func uploadHandler(c *gin.Context) {
-	buf, filename, n, err := getFileFromCtx(c)
+	buf, filename, _, err := getFileFromCtx(c)
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// We only accept exactly 512 bytes
-	if n < 512 || n > 512 {
-		c.String(http.StatusInternalServerError, "invalid bin size")
-		return
-	}
-
-	fw, err := cim.LoadBytes(filename, buf)
+	fw, _, err := cim.Decode(bytes.NewReader(buf))
 	if err != nil {
-		c.String(http.StatusInternalServerError, err.Error())
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := fw.Validate(); err != nil {
-		c.String(http.StatusBadRequest, err.Error())
+	if r := fw.Report(); !r.OK() {
+		c.HTML(http.StatusBadRequest, "report.tmpl", gin.H{
+			"filename": filepath.Base(filename),
+			"report":   r,
+		})
 		return
 	}
 
-	fwBytes, err := fw.Bytes()
+	renderView(c, filename, fw)
+}
+
+// Render the editor for fw
+func renderView(c *gin.Context, filename string, fw *cim.Bin) {
+	fwBytes, err := fw.Image()
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
@@ -67,9 +72,17 @@ func uploadHandler(c *gin.Context) {
 	styles := generateStyles(sections)
 	jsSections := jsSections(sections)
 
+	var vinError string
+	vin, err := fw.Vin.Decode()
+	if err != nil && !fw.IsVirgin() {
+		vinError = err.Error()
+	}
+
 	c.HTML(http.StatusOK, "view.tmpl", gin.H{
 		"filename": filepath.Base(filename),
 		"fw":       fw,
+		"vin":      vin,
+		"vinError": vinError,
 		"B64":      base64.StdEncoding.EncodeToString(fwBytes),
 		"Hexview":  template.HTML(hexRows),
 		"sections": template.JS(jsSections),
@@ -77,6 +90,100 @@ func uploadHandler(c *gin.Context) {
 	})
 }
 
+// loadPostedFile loads the base64 dump posted by the editor forms, on error
+// the response is written and nil returned
+func loadPostedFile(c *gin.Context) (*cim.Bin, string) {
+	file := c.PostForm("file")
+	filename := c.PostForm("filename")
+
+	if file == "" || filename == "" {
+		c.String(http.StatusBadRequest, "missing file or filename")
+		return nil, ""
+	}
+
+	b, err := base64.StdEncoding.DecodeString(file)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return nil, ""
+	}
+
+	fw, err := cim.MustLoadBytes(filename, b)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return nil, ""
+	}
+	return fw, filename
+}
+
+// Return the dump to a factory like, unmarried state and show it in the editor
+func virginizeHandler(c *gin.Context) {
+	fw, filename := loadPostedFile(c)
+	if fw == nil {
+		return
+	}
+
+	if err := fw.Virginize(); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	renderView(c, filename, fw)
+}
+
+// Add, remove or move a key and show the result in the editor
+func keysHandler(c *gin.Context) {
+	fw, filename := loadPostedFile(c)
+	if fw == nil {
+		return
+	}
+
+	slot := func(name string) (uint8, error) {
+		n, err := strconv.ParseUint(c.PostForm(name), 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %q", name, c.PostForm(name))
+		}
+		return uint8(n), nil
+	}
+
+	e, err := fw.Edit()
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch c.PostForm("action") {
+	case "add":
+		var id []byte
+		if id, err = hex.DecodeString(c.PostForm("id")); err == nil {
+			_, err = e.Keys.AddKey(id)
+		}
+	case "remove":
+		var from uint8
+		if from, err = slot("slot"); err == nil {
+			err = e.Keys.RemoveKey(from)
+		}
+	case "move":
+		var from, to uint8
+		if from, err = slot("slot"); err == nil {
+			if to, err = slot("to"); err == nil {
+				err = e.Keys.MoveKey(from, to)
+			}
+		}
+	case "clear":
+		e.Keys.ClearUnusedSlots()
+	default:
+		err = fmt.Errorf("unknown key action %q", c.PostForm("action"))
+	}
+	if err == nil {
+		_, err = e.Commit()
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	renderView(c, filename, fw)
+}
+
 func jsSections(sections []Section) string {
 	js := strings.Builder{}
 	js.WriteString(`var sections = [`)
@@ -154,17 +261,70 @@ func saveHandler(c *gin.Context) {
 		return
 	}
 
-	bs, err := fw.XORBytes()
+	format, err := cim.ParseFormat(c.DefaultPostForm("format", string(cim.FormatInverted)))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var bs bytes.Buffer
+	if name := c.PostForm("profile"); name != "" {
+		p, err := cim.ProfileByName(name)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		err = cim.EncodeProfile(&bs, fw, format, p)
+	} else {
+		err = cim.Encode(&bs, fw, format)
+	}
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	contentLength := int64(len(bs))
+	// Text formats get their extension, binaries keep the uploaded name
+	filename = filepath.Base(filename)
+	if codec, ok := cim.CodecByFormat(format); ok && len(codec.Extensions) > 0 {
+		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + codec.Extensions[0]
+	}
+
+	contentLength := int64(bs.Len())
 	contentType := "application/octet-stream"
 
 	extraHeaders := map[string]string{
-		"Content-Disposition": `attachment; filename="` + filepath.Base(filename) + `"`,
+		"Content-Disposition": `attachment; filename="` + filename + `"`,
+	}
+	c.DataFromReader(http.StatusOK, contentLength, contentType, &bs, extraHeaders)
+}
+
+type inspectRequest struct {
+	File   string `json:"file"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// Return every interpretation of a byte range of the posted dump
+func inspectHandler(c *gin.Context) {
+	var req inspectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(req.File)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	fw, err := cim.LoadBytes("", b)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	interpretations, err := jesus.InspectBin(fw, req.Offset, req.Length)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
 	}
-	c.DataFromReader(http.StatusOK, contentLength, contentType, bytes.NewReader(bs), extraHeaders)
+	c.JSON(http.StatusOK, interpretations)
 }
@@ -3,10 +3,10 @@ package server
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
-	"reflect"
 	"strings"
 
 	"github.com/roffe/cim/pkg/cim"
@@ -17,12 +17,15 @@ type Section struct {
 	Start  int    `json:"start"`
 	Length int    `json:"length"`
 	//Confirmed bool
-	Checksum bool
-	Type     string `json:"type"`
+	Checksum    bool
+	Type        string `json:"type"`
+	Description string `json:"description"`
 }
 
 func (s *Section) String() string {
-	return fmt.Sprintf(`{id: "%s", start: 0x%02X, length: %d, type: "%s", checksum: %t}`, s.ID, s.Start, s.Length, s.Type, s.Checksum)
+	// json escapes < and > so a description can't close the script tag
+	desc, _ := json.Marshal(s.Description)
+	return fmt.Sprintf(`{id: "%s", start: 0x%02X, length: %d, type: "%s", checksum: %t, description: %s}`, s.ID, s.Start, s.Length, s.Type, s.Checksum, desc)
 }
 
 func generateStyles(sections []Section) template.CSS {
@@ -44,68 +47,46 @@ func generateStyles(sections []Section) template.CSS {
 }
 
 func generateSections(fw *cim.Bin) []Section {
-	// Byte position in the file
-	var offset int
 	var sections []Section
-
-	// create a empty anonymous function
-	var loop func(string, reflect.Value)
-
-	// Define the function with references to itself via anonymous function
-	loop = func(prefix string, valueOf reflect.Value) {
-		for i := 0; i < valueOf.NumField(); i++ {
-			tag := valueOf.Type().Field(i).Tag.Get("bin")
-			if tag == "-" || tag == "" {
-				continue
-			}
-
-			field := valueOf.Field(i)
-			fieldName := valueOf.Type().Field(i).Name
-			typeName := valueOf.Type().Field(i).Type.String()
-
-			switch typeName {
-			// time.Time is a struct and we don't want to itterate over any sub structures in it
-			case "time.Time":
-			default:
-				// Recurse any structures
-				if field.Kind() == reflect.Struct {
-					loop(fieldName, field)
-					continue
-				}
-			}
-			// Keep track of previous bin len value for multi dimension arrays
-			var previous int
-			for _, p := range strings.Split(tag, ",") {
-				var length, nlength int
-				if _, err := fmt.Sscanf(p, "[len:%d]", &nlength); err == nil {
-					structLen := (previous*nlength - previous)
-					offset += structLen
-					sections[len(sections)-1].Length = previous * nlength
-					previous = 0
-					continue
-				}
-				if _, err := fmt.Sscanf(p, "len:%d", &length); err != nil {
-					continue
-				}
-				previous = length
-				fname := genFieldName(prefix, fieldName)
-				sections = append(sections, Section{
-					ID:       fname,
-					Start:    offset,
-					Length:   length,
-					Type:     typeName,
-					Checksum: strings.Contains(fname, "CHECKSUM"),
-				})
-				offset += length
-			}
+	for _, f := range cim.Fields() {
+		var prefix string
+		if len(f.Path) > 1 {
+			prefix = f.Path[len(f.Path)-2]
+		}
+		fname := genFieldName(prefix, f.Path[len(f.Path)-1])
+		sections = append(sections, Section{
+			ID:       fname,
+			Start:    f.Offset,
+			Length:   f.Length,
+			Type:     f.Type,
+			Checksum: strings.Contains(fname, "CHECKSUM"),
+		})
+	}
+	// Overlay fields come last so they win the highlight of their bytes
+	if o := cim.ActiveOverlay(); o != nil {
+		for _, f := range o.Fields {
+			sections = append(sections, Section{
+				ID:          "OVERLAY_" + sectionID(f.Name),
+				Start:       f.Offset,
+				Length:      f.Length,
+				Type:        f.Type,
+				Description: f.Description,
+			})
 		}
 	}
-	// start the recursing
-	loop("", reflect.ValueOf(*fw))
-
 	return sections
 }
 
+// sectionID makes name usable as a css class
+func sectionID(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
+
 func genFieldName(prefix, name string) string {
 	var fname string
 	if prefix == "" {
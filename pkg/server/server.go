@@ -52,6 +52,9 @@ func setupRouter(enableShutdown bool, path string) (*gin.Engine, error) {
 	r.POST(p(path, "/save"), saveHandler)
 	r.POST(p(path, "/"), uploadHandler)
 	r.POST(p(path, "/update"), updateHandler)
+	r.POST(p(path, "/virginize"), virginizeHandler)
+	r.POST(p(path, "/keys"), keysHandler)
+	r.POST(p(path, "/inspect"), inspectHandler)
 	r.GET(p(path, "/favicon.ico"), faviconHandler)
 
 	if enableShutdown {
@@ -133,6 +136,14 @@ func loadTemplates(r *gin.Engine) error {
 			}
 			return template.HTML(out.String())
 		},
+		"formats": func() []string {
+			var out []string
+			for _, c := range cim.Codecs() {
+				out = append(out, string(c.Format))
+			}
+			return out
+		},
+		"profiles": cim.Profiles,
 	}
 
 	if tmpl, err := template.New("views").Funcs(templateHelpers).ParseFS(tp, "templates/*.tmpl"); err == nil {
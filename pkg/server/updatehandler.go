@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roffe/cim/pkg/cim"
@@ -57,28 +59,34 @@ func updateHandler(c *gin.Context) {
 		return
 	}
 
-	if err := fw.Pin.Set(u.Pin); err != nil {
+	e, err := fw.Edit()
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := e.Pin.Set(u.Pin); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := updateVin(fw, u); err != nil {
+	if err := updateVin(e.Bin, u); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if u.Sas == "on" {
-		fw.SetSasOpt(true)
+		e.SetSasOpt(true)
 	} else {
-		fw.SetSasOpt(false)
+		e.SetSasOpt(false)
 	}
 
-	if err := updateKeys(fw, u); err != nil {
+	if err := updateKeys(e.Bin, u); err != nil {
 		c.String(http.StatusBadRequest, fmt.Sprintf("invalid key value: %v", err))
 		return
 	}
 
-	if err := updateSync(fw, u); err != nil {
+	if err := updateSync(e.Bin, u); err != nil {
 		c.String(http.StatusBadRequest, fmt.Sprintf("invalid sync data: %v", err))
 		return
 	}
@@ -88,19 +96,36 @@ func updateHandler(c *gin.Context) {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
-	fw.SetConfVer(uint32(confVer))
+	e.SetConfVer(uint32(confVer))
 
 	for i, s := range u.ProgID {
-		if err := fw.SetProgrammingID(i, s); err != nil {
+		if err := e.SetProgrammingID(i, s); err != nil {
 			c.String(http.StatusBadRequest, fmt.Sprintf("invalid programming id %d value: %s: %v", i, s, err))
 			return
 		}
 	}
-	if err := updatePSK(fw, u); err != nil {
+	if err := updatePSK(e.Bin, u); err != nil {
 		c.String(http.StatusBadRequest, fmt.Sprintf("invalid PSK data: %v", err))
+		return
+	}
+
+	if err := updatePartNumbers(e.Bin, u); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := updateDates(e.Bin, u); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
 	}
 
-	fwBytes, err := fw.Bytes()
+	touched, err := e.Commit()
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fwBytes, err := fw.Image()
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
@@ -117,6 +142,7 @@ func updateHandler(c *gin.Context) {
 		"crc32":   fw.CRC32(),
 		"B64":     base64.StdEncoding.EncodeToString(fwBytes),
 		"hexview": hexRows,
+		"touched": touched,
 	})
 }
 
@@ -139,8 +165,12 @@ func updatePSK(fw *cim.Bin, u updateRequest) error {
 }
 
 func updateVin(fw *cim.Bin, u updateRequest) error {
-	if err := fw.Vin.Set(u.Vin); err != nil {
-		return fmt.Errorf("failed to set vin: %v", err)
+	// Only validated when changed, so dumps holding a vin Set rejects can
+	// still be edited
+	if strings.TrimSpace(u.Vin) != strings.TrimSpace(fw.Vin.Data) {
+		if err := fw.Vin.Set(u.Vin); err != nil {
+			return fmt.Errorf("failed to set vin: %v", err)
+		}
 	}
 
 	if n, err := strconv.ParseUint(u.VinValue, 0, 8); err == nil {
@@ -163,8 +193,9 @@ func updateSync(fw *cim.Bin, u updateRequest) error {
 		if err != nil {
 			return fmt.Errorf("failed to decode sync data %d: %v", i, err)
 		}
-		fw.Sync.SetData(uint8(i), syncData)
-
+		if err := fw.Sync.SetData(uint8(i), syncData); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -184,11 +215,13 @@ func updateKeys(fw *cim.Bin, u updateRequest) error {
 		return fmt.Errorf("failed to set ISK: %v", err)
 	}
 
-	if n, err := strconv.ParseUint(u.KeyCount, 0, 8); err == nil {
-		fw.Keys.SetKeyCount(uint8(n))
-	} else {
+	n, err := strconv.ParseUint(u.KeyCount, 0, 8)
+	if err != nil {
 		return fmt.Errorf("failed to parse key count: %q %s", u.KeyCount, err.Error())
 	}
+	if err := fw.Keys.SetKeyCount(uint8(n)); err != nil {
+		return err
+	}
 
 	for i, k := range u.Key {
 		b, err := hex.DecodeString(k)
@@ -201,3 +234,73 @@ func updateKeys(fw *cim.Bin, u updateRequest) error {
 	}
 	return nil
 }
+
+func updatePartNumbers(fw *cim.Bin, u updateRequest) error {
+	sn, err := strconv.ParseUint(u.Snsticker, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse serial sticker: %q %s", u.Snsticker, err.Error())
+	}
+	// An erased serial decodes out of range, only set it when edited
+	if sn != fw.SnSticker {
+		if err := fw.SetSnSticker(sn); err != nil {
+			return err
+		}
+	}
+
+	for _, pn := range []struct {
+		name  string
+		value string
+		set   func(uint32)
+	}{
+		{"partno1", u.Partno1, fw.SetPartNo1},
+		{"pnbase1", u.Pnbase1, fw.SetPnBase1},
+		{"delphi part number", u.Pndelphi, fw.SetDelphiPN},
+		{"partno", u.Partno, fw.SetPartNo},
+	} {
+		n, err := strconv.ParseUint(pn.value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %q %s", pn.name, pn.value, err.Error())
+		}
+		pn.set(uint32(n))
+	}
+
+	// Revisions are only validated when changed, dumps may hold revisions
+	// the editor can't produce
+	if strings.TrimSpace(u.Partno1Rev) != strings.TrimSpace(fw.PartNo1Rev) {
+		if err := fw.SetPartNo1Rev(u.Partno1Rev); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(u.Pnbase1Rev) != strings.TrimSpace(fw.PnBase1Rev) {
+		if err := fw.SetPnBase1Rev(u.Pnbase1Rev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateDates sets the dates that changed, an erased date shows as
+// 0001-01-01 and is kept as is unless edited
+func updateDates(fw *cim.Bin, u updateRequest) error {
+	for _, d := range []struct {
+		name    string
+		value   string
+		current time.Time
+		set     func(time.Time) error
+	}{
+		{"programming date", u.ProgrammingDate, fw.ProgrammingDate, fw.SetProgrammingDate},
+		{"factory date", u.FpDate, fw.ProgrammingFactoryDate, fw.SetFactoryDate},
+	} {
+		if d.value == d.current.Format(cim.IsoDate) {
+			continue
+		}
+		t, err := time.Parse(cim.IsoDate, d.value)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %q %s", d.name, d.value, err.Error())
+		}
+		if err := d.set(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package vin decodes and validates Saab/GM vehicle identification numbers.
+//
+// The position layout follows ISO 3779, the code tables hold the Saab codes
+// known so far and can be extended by the caller. Codes missing from a table
+// are still decoded, they only lack a description.
+package vin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Length of a VIN
+const Length = 17
+
+// Code is a decoded VIN position and its description if known
+type Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+func (c Code) String() string {
+	if c.Description == "" {
+		return fmt.Sprintf("unknown (%s)", c.Code)
+	}
+	return fmt.Sprintf("%s (%s)", c.Description, c.Code)
+}
+
+// Manufacturers by world manufacturer identifier, position 1-3
+var Manufacturers = map[string]string{
+	"YS3": "Saab Automobile AB, passenger car",
+	"YS4": "Saab Automobile AB, passenger car",
+	"YK1": "Saab Automobile AB, passenger car (Valmet)",
+}
+
+// ModelLines by position 4
+var ModelLines = map[string]string{
+	"D": "9-3 (1998-2002)",
+	"E": "9-5 (1998-2010)",
+	"F": "9-3 (2003-2012)",
+	"G": "9-5 (2010-2012)",
+}
+
+// BodyStyles by position 6
+var BodyStyles = map[string]string{
+	"3": "3-door hatchback",
+	"4": "4-door sedan",
+	"5": "5-door hatchback/wagon",
+	"7": "2-door convertible",
+}
+
+// RestraintSystems by position 7, no Saab codes are known yet so the code
+// is decoded without a description
+var RestraintSystems = map[string]string{}
+
+// Engines by position 8
+var Engines = map[string]string{
+	"S": "B207L 2.0t",
+	"Y": "B207E 2.0t",
+	"U": "B207R 2.0T",
+	"T": "B284R 2.8T V6",
+}
+
+// Plants by position 11
+var Plants = map[string]string{
+	"1": "Trollhättan, Sweden",
+}
+
+// LatestModelYear resolves the 30 year model year cycle, a code stands for
+// its most recent year up to it. It's fixed rather than taken from the clock
+// so a VIN always decodes to the same year, the default is the last Saab
+// model year.
+var LatestModelYear = 2014
+
+// modelYearCodes in the order of the 30 year cycle starting 1980
+const modelYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// VIN is a decoded VIN
+type VIN struct {
+	VIN          string `json:"vin"`
+	WMI          string `json:"wmi"`
+	Manufacturer string `json:"manufacturer"`
+	ModelLine    Code   `json:"model_line"`
+	Series       string `json:"series"`
+	BodyStyle    Code   `json:"body_style"`
+	Restraint    Code   `json:"restraint"`
+	Engine       Code   `json:"engine"`
+	CheckDigit   string `json:"check_digit"`
+	// CheckDigitValid is only binding for North American cars, European
+	// VINs often carry a different character in position 9
+	CheckDigitValid bool `json:"check_digit_valid"`
+	// ModelYear is the most recent candidate year up to LatestModelYear
+	ModelYear int `json:"model_year"`
+	// Every year from 1980 up to LatestModelYear the model year code stands
+	// for
+	ModelYearCandidates []int  `json:"model_year_candidates"`
+	Plant               Code   `json:"plant"`
+	Serial              string `json:"serial"`
+}
+
+// Ambiguous reports if the model year code maps to more than one year up to
+// LatestModelYear
+func (v *VIN) Ambiguous() bool {
+	return len(v.ModelYearCandidates) > 1
+}
+
+// Validate checks length, character set and model year code of vin
+func Validate(vin string) error {
+	if len(vin) != Length {
+		return fmt.Errorf("vin %q must be %d characters, got %d", vin, Length, len(vin))
+	}
+	for i, c := range vin {
+		switch {
+		case c == 'I' || c == 'O' || c == 'Q':
+			return fmt.Errorf("vin %q position %d: %c is not allowed in a vin", vin, i+1, c)
+		case (c < 'A' || c > 'Z') && (c < '0' || c > '9'):
+			return fmt.Errorf("vin %q position %d: invalid character %q", vin, i+1, c)
+		}
+	}
+	if !strings.ContainsRune(modelYearCodes, rune(vin[9])) {
+		return fmt.Errorf("vin %q position 10: %c is not a model year code", vin, vin[9])
+	}
+	return nil
+}
+
+// Decode validates and decodes vin
+func Decode(vin string) (*VIN, error) {
+	vin = strings.ToUpper(strings.TrimSpace(vin))
+	if err := Validate(vin); err != nil {
+		return nil, err
+	}
+	manufacturer, saab := Manufacturers[vin[0:3]]
+	lookup := func(table map[string]string, code string) Code {
+		if !saab {
+			// The code tables are Saab specific
+			return Code{Code: code}
+		}
+		return Code{Code: code, Description: table[code]}
+	}
+	v := &VIN{
+		VIN:          vin,
+		WMI:          vin[0:3],
+		Manufacturer: manufacturer,
+		ModelLine:    lookup(ModelLines, vin[3:4]),
+		Series:       vin[4:5],
+		BodyStyle:    lookup(BodyStyles, vin[5:6]),
+		Restraint:    lookup(RestraintSystems, vin[6:7]),
+		Engine:       lookup(Engines, vin[7:8]),
+		CheckDigit:   vin[8:9],
+		Plant:        lookup(Plants, vin[10:11]),
+		Serial:       vin[11:],
+	}
+	v.CheckDigitValid = CheckDigit(vin) == vin[8]
+	v.ModelYearCandidates = ModelYears(vin[9])
+	v.ModelYear = resolveModelYear(v.ModelYearCandidates, LatestModelYear)
+	return v, nil
+}
+
+var transliteration = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+var weights = [Length]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// CheckDigit calculates the position 9 check digit of vin (0-9 or X)
+func CheckDigit(vin string) byte {
+	var sum int
+	for i, c := range vin {
+		if i >= Length {
+			break
+		}
+		v, ok := transliteration[c]
+		if !ok && c >= '0' && c <= '9' {
+			v = int(c - '0')
+		}
+		sum += v * weights[i]
+	}
+	if sum%11 == 10 {
+		return 'X'
+	}
+	return byte('0' + sum%11)
+}
+
+// ModelYears returns every year from 1980 up to LatestModelYear the model
+// year code stands for
+func ModelYears(code byte) []int {
+	i := strings.IndexByte(modelYearCodes, code)
+	if i == -1 {
+		return nil
+	}
+	var years []int
+	for year := 1980 + i; year <= LatestModelYear; year += len(modelYearCodes) {
+		years = append(years, year)
+	}
+	return years
+}
+
+// resolveModelYear picks the most recent candidate that is not after latest
+func resolveModelYear(candidates []int, latest int) int {
+	var year int
+	for _, c := range candidates {
+		if c <= latest && c > year {
+			year = c
+		}
+	}
+	return year
+}
@@ -0,0 +1,141 @@
+package vin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckDigit(t *testing.T) {
+	for _, tc := range []struct {
+		vin  string
+		want byte
+	}{
+		// Published examples
+		{"1M8GDM9AXKP042788", 'X'},
+		{"11111111111111111", '1'},
+		// Saab
+		{"YS3FD49Y691012345", '6'},
+		{"YS3FH41U181012345", '1'},
+		{"YS3EF48E6Y3012345", '6'},
+		{"YS3FD79Y961234567", '9'},
+		{"YS3DF75K8X7012345", '8'},
+	} {
+		if got := CheckDigit(tc.vin); got != tc.want {
+			t.Errorf("CheckDigit(%s) = %c, want %c", tc.vin, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, vin := range []string{
+		"YS3FD49Y691012345",
+		"YS3EF48E6Y3012345",
+		"YS3DF75K8X7012345",
+		// The check digit is not binding for European cars
+		"YS3FD49Y291012345",
+	} {
+		if err := Validate(vin); err != nil {
+			t.Errorf("Validate(%s): %v", vin, err)
+		}
+	}
+
+	for _, vin := range []string{
+		"",
+		"YS3FD49Y69101234",   // 16 characters
+		"YS3FD49Y6910123456", // 18 characters
+		"YS3FD49Y6I1012345",  // I is not allowed
+		"YS3FD49Y691O12345",  // O is not allowed
+		"YS3FQ49Y691012345",  // Q is not allowed
+		"ys3fd49y691012345",  // lower case
+		"YS3FD49Y-91012345",  // not alphanumeric
+		"YS3FD49Y6U1012345",  // U is not a model year code
+		"YS3FD49Y601012345",  // 0 is not a model year code
+		"YS3FD49Y6Z1012345",  // Z is not a model year code
+	} {
+		if err := Validate(vin); err == nil {
+			t.Errorf("Validate(%q) accepted an invalid vin", vin)
+		}
+	}
+}
+
+func TestModelYear(t *testing.T) {
+	for _, tc := range []struct {
+		code       byte
+		want       int
+		candidates []int
+	}{
+		{'A', 2010, []int{1980, 2010}},
+		{'E', 2014, []int{1984, 2014}},
+		{'F', 1985, []int{1985}},
+		{'Y', 2000, []int{2000}},
+		{'1', 2001, []int{2001}},
+		{'9', 2009, []int{2009}},
+	} {
+		vin := "YS3FD49Y0" + string(tc.code) + "1012345"
+		vin = vin[:8] + string(CheckDigit(vin)) + vin[9:]
+		v, err := Decode(vin)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", vin, err)
+		}
+		if v.ModelYear != tc.want {
+			t.Errorf("%s: model year %d, want %d", vin, v.ModelYear, tc.want)
+		}
+		if !reflect.DeepEqual(v.ModelYearCandidates, tc.candidates) {
+			t.Errorf("%s: candidates %v, want %v", vin, v.ModelYearCandidates, tc.candidates)
+		}
+		if v.Ambiguous() != (len(tc.candidates) > 1) {
+			t.Errorf("%s: Ambiguous() = %v", vin, v.Ambiguous())
+		}
+		if !v.CheckDigitValid {
+			t.Errorf("%s: check digit reported invalid", vin)
+		}
+	}
+
+	defer func(latest int) { LatestModelYear = latest }(LatestModelYear)
+	LatestModelYear = 2020
+	v, err := Decode("YS3FD49Y0F1012345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ModelYear != 2015 {
+		t.Errorf("model year %d with LatestModelYear 2020, want 2015", v.ModelYear)
+	}
+	if !v.Ambiguous() {
+		t.Errorf("F reported unambiguous with LatestModelYear 2020")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	v, err := Decode(" ys3fd49y691012345 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VIN{
+		VIN:                 "YS3FD49Y691012345",
+		WMI:                 "YS3",
+		Manufacturer:        "Saab Automobile AB, passenger car",
+		ModelLine:           Code{"F", "9-3 (2003-2012)"},
+		Series:              "D",
+		BodyStyle:           Code{"4", "4-door sedan"},
+		Restraint:           Code{"9", ""},
+		Engine:              Code{"Y", "B207E 2.0t"},
+		CheckDigit:          "6",
+		CheckDigitValid:     true,
+		ModelYear:           2009,
+		ModelYearCandidates: []int{2009},
+		Plant:               Code{"1", "Trollhättan, Sweden"},
+		Serial:              "012345",
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Decode =\n%+v\nwant\n%+v", v, want)
+	}
+
+	// Code tables are Saab specific
+	v, err = Decode("1M8GDM9AXKP042788")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Manufacturer != "" || v.ModelLine.Description != "" {
+		t.Errorf("non Saab vin decoded with Saab tables: %+v", v)
+	}
+}
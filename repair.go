@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func repairCmd(args []string) error {
+	fs := newFlagSet("repair")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the repaired dump to file, dry run if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf(fs, "repair takes exactly one file")
+	}
+
+	fw, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	l, err := fw.Repair()
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		b, err := json.MarshalIndent(l, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Print(l)
+	}
+
+	if *write != "" && len(l.Actions) > 0 {
+		if err := saveFile(*write, fw); err != nil {
+			return err
+		}
+	}
+	if !l.Unrepaired.OK() {
+		return &exitError{exitInvalid, fmt.Errorf("%d problem(s) left unrepaired", len(l.Unrepaired.Problems))}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// scanRow is the summary of one scanned file. Status is ok, invalid when the
+// dump fails validation or error when the file can't be loaded.
+type scanRow struct {
+	File      string   `json:"file"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Layout    string   `json:"layout,omitempty"`
+	VIN       string   `json:"vin,omitempty"`
+	ModelYear int      `json:"model_year,omitempty"`
+	KeyCount  *uint8   `json:"key_count,omitempty"` // nil when not loaded
+	SpsCount  *uint8   `json:"sps_count,omitempty"`
+	PartNo1   string   `json:"partno1,omitempty"`
+	PnBase1   string   `json:"pnbase1,omitempty"`
+	PartNo    uint32   `json:"partno,omitempty"`
+	DelphiPN  uint32   `json:"delphipn,omitempty"`
+	MD5       string   `json:"md5,omitempty"`
+	CRC32     string   `json:"crc32,omitempty"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+var scanColumns = []string{
+	"file", "status", "error", "format", "layout", "vin", "model_year", "key_count", "sps_count",
+	"partno1", "pnbase1", "partno", "delphipn", "md5", "crc32", "problems",
+}
+
+func (r *scanRow) csv() []string {
+	// Empty rather than zero for values that weren't read
+	num := func(n uint64) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.FormatUint(n, 10)
+	}
+	count := func(n *uint8) string {
+		if n == nil {
+			return ""
+		}
+		return strconv.Itoa(int(*n))
+	}
+	return []string{
+		r.File, r.Status, r.Error, r.Format, r.Layout, r.VIN, num(uint64(r.ModelYear)), count(r.KeyCount), count(r.SpsCount),
+		r.PartNo1, r.PnBase1, num(uint64(r.PartNo)), num(uint64(r.DelphiPN)), r.MD5, r.CRC32, strings.Join(r.Problems, "; "),
+	}
+}
+
+// scanCmd validates every file below the given directories and exits like
+// validate, with exitInvalid if any dump fails validation or exitFailure if
+// any file can't be loaded
+func scanCmd(args []string) error {
+	fs := newFlagSet("scan")
+	addInFormatFlag(fs)
+	output := fs.StringP("output", "o", "csv", "csv|json, json writes one object per line")
+	workers := fs.IntP("workers", "j", runtime.NumCPU(), "number of files loaded at once")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf(fs, "scan takes one or more directories")
+	}
+	if *workers < 1 {
+		return usageErrorf(fs, "--workers must be at least 1")
+	}
+
+	var write func(*scanRow) error
+	switch strings.ToLower(*output) {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		write = func(r *scanRow) error { return enc.Encode(r) }
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(scanColumns); err != nil {
+			return err
+		}
+		write = func(r *scanRow) error {
+			if err := w.Write(r.csv()); err != nil {
+				return err
+			}
+			// Flush every row so a long scan can be followed
+			w.Flush()
+			return w.Error()
+		}
+	default:
+		return usageErrorf(fs, "unknown output %q", *output)
+	}
+
+	// Rows are written in walk order, each file gets its own result channel
+	// queued in pending while at most workers files are loaded at once
+	jobs := make(chan func(), *workers)
+	pending := make(chan chan *scanRow, *workers)
+	for i := 0; i < *workers; i++ {
+		go func() {
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for _, dir := range fs.Args() {
+			filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && d.IsDir() {
+					return nil
+				}
+				res := make(chan *scanRow, 1)
+				pending <- res
+				if err != nil {
+					// Unreadable directory or missing argument
+					res <- &scanRow{File: path, Status: "error", Error: err.Error()}
+					return skipDir(d)
+				}
+				jobs <- func() { res <- scanFile(path) }
+				return nil
+			})
+		}
+	}()
+
+	code := 0
+	var writeErr error
+	for res := range pending {
+		r := <-res
+		switch {
+		case r.Status == "error":
+			code = exitFailure
+		case r.Status == "invalid" && code == 0:
+			code = exitInvalid
+		}
+		// Keep draining so the walk and the workers finish
+		if writeErr == nil {
+			writeErr = write(r)
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+// skipDir skips the rest of a directory that failed to be read
+func skipDir(d os.DirEntry) error {
+	if d != nil && d.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// scanFile loads and validates one file
+func scanFile(path string) *scanRow {
+	r := &scanRow{File: path}
+	fw, err := loadFile(path)
+	if err != nil {
+		r.Status, r.Error = "error", err.Error()
+		return r
+	}
+	r.Format = string(fw.Format())
+	r.Layout = fw.Layout().Name
+	r.VIN = strings.TrimSpace(fw.Vin.Data)
+	r.ModelYear = fw.ModelYear()
+	r.KeyCount, r.SpsCount = &fw.Keys.Count1, &fw.Vin.SpsCount
+	r.PartNo1 = fmt.Sprintf("%d%s", fw.PartNo1, strings.TrimSpace(fw.PartNo1Rev))
+	r.PnBase1 = fmt.Sprintf("%d%s", fw.PnBase1, strings.TrimSpace(fw.PnBase1Rev))
+	r.PartNo = fw.PartNo
+	r.DelphiPN = fw.DelphiPN
+
+	report := fw.Report()
+	if _, err := fw.Image(); err == nil {
+		r.MD5, r.CRC32 = fw.MD5(), fw.CRC32()
+	}
+	r.Status = "ok"
+	if !report.OK() {
+		r.Status = "invalid"
+		for _, p := range report.Problems {
+			r.Problems = append(r.Problems, p.Error())
+		}
+	}
+	return r
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+// scanOutput runs scan with json output and returns the rows and exit code
+func scanOutput(t *testing.T, args ...string) ([]scanRow, int) {
+	t.Helper()
+	out, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	code := exitCode(scanCmd(append([]string{"--output", "json", "--workers", "2"}, args...)))
+	os.Stdout = stdout
+
+	if _, err := out.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	var rows []scanRow
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		var r scanRow
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("%q: %v", s.Text(), err)
+		}
+		rows = append(rows, r)
+	}
+	return rows, code
+}
+
+func TestScan(t *testing.T) {
+	fw, err := cim.LoadBytes("valid.bin", append([]byte{0x20}, make([]byte, cim.Size-1)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Vin.Set("YS3FD49Y691012345"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.UpdateChecksums(); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := fw.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A flipped VIN byte breaks the VIN checksum
+	corrupt := append([]byte(nil), valid...)
+	corrupt[27] ^= 0x01
+
+	dir := t.TempDir()
+	files := []struct {
+		name   string
+		data   []byte
+		status string
+		code   int
+	}{
+		{"a-valid.bin", valid, "ok", 0},
+		{"b-corrupt.bin", corrupt, "invalid", exitInvalid},
+		{"c-notes.txt", []byte("not a dump"), "error", exitFailure},
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), f.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range files {
+		t.Run(f.name, func(t *testing.T) {
+			path := filepath.Join(dir, f.name)
+			r := scanFile(path)
+			if r.File != path || r.Status != f.status {
+				t.Errorf("scanFile() = %s %s, want %s", r.File, r.Status, f.status)
+			}
+			switch f.status {
+			case "ok":
+				if r.VIN != "YS3FD49Y691012345" || r.ModelYear != 2009 || len(r.Problems) != 0 || r.MD5 == "" {
+					t.Errorf("scanFile() = %+v", r)
+				}
+			case "invalid":
+				if len(r.Problems) == 0 {
+					t.Errorf("no problems reported")
+				}
+			case "error":
+				if r.Error == "" || r.KeyCount != nil {
+					t.Errorf("scanFile() = %+v, want only an error", r)
+				}
+			}
+
+			rows, code := scanOutput(t, path)
+			if code != f.code {
+				t.Errorf("exit code %d, want %d", code, f.code)
+			}
+			if len(rows) != 1 || rows[0].Status != f.status {
+				t.Errorf("scan wrote %+v", rows)
+			}
+		})
+	}
+
+	// The whole directory is written in walk order and fails with the worst
+	// result
+	rows, code := scanOutput(t, dir, filepath.Join(dir, "missing"))
+	if code != exitFailure {
+		t.Errorf("exit code %d, want %d", code, exitFailure)
+	}
+	if len(rows) != len(files)+1 {
+		t.Fatalf("scan wrote %d rows, want %d", len(rows), len(files)+1)
+	}
+	for i, f := range files {
+		if rows[i].File != filepath.Join(dir, f.name) || rows[i].Status != f.status {
+			t.Errorf("row %d = %s %s, want %s %s", i, rows[i].File, rows[i].Status, f.name, f.status)
+		}
+	}
+	if r := rows[len(files)]; r.Status != "error" {
+		t.Errorf("missing directory %+v, want an error", r)
+	}
+
+	// Without errors an invalid dump gives exitInvalid
+	if _, code := scanOutput(t, filepath.Join(dir, "a-valid.bin"), filepath.Join(dir, "b-corrupt.bin")); code != exitInvalid {
+		t.Errorf("exit code %d, want %d", code, exitInvalid)
+	}
+}
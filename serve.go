@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/roffe/cim/pkg/server"
+)
+
+var (
+	enableShutdown = true
+	httpPath       = ""
+)
+
+func serveCmd(args []string) error {
+	fs := newFlagSet("serve")
+	fs.BoolVarP(&enableShutdown, "shutdown", "s", enableShutdown, "true|false enable shutdown api")
+	fs.StringVar(&httpPath, "path", httpPath, "set http path")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf(fs, "serve takes no arguments")
+	}
+	return serve()
+}
+
+// serve runs the web ui
+func serve() error {
+	fmt.Println("Server started @ http://localhost:8080")
+	return server.Run(enableShutdown, httpPath)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+func setCmd(args []string) error {
+	fs := newFlagSet("set")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the changed dump to file, dry run if empty")
+	list := fs.Bool("list", false, "list the fields that can be set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *list {
+		for _, name := range cim.SettableFields() {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	if fs.NArg() != 3 {
+		return usageErrorf(fs, "set takes a field, a value and a file")
+	}
+	return applyOps(fs.Arg(2), *output, *write, cim.PatchOp{Set: fs.Arg(0), Value: fs.Arg(1)})
+}
+
+// applyOps applies ops to the dump in filename as a patch, prints the
+// changes in the output format and writes the result to write if not empty
+func applyOps(filename, output, write string, ops ...cim.PatchOp) error {
+	fw, err := loadFile(filename)
+	if err != nil {
+		return err
+	}
+	p := &cim.Patch{Ops: ops}
+	out, err := p.Apply(fw)
+	if err != nil {
+		return err
+	}
+	changes, err := cim.Diff(fw, out)
+	if err != nil {
+		return err
+	}
+	if err := printChanges(output, changes); err != nil {
+		return err
+	}
+
+	if write != "" {
+		return saveFile(write, out)
+	}
+	return nil
+}
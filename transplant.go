@@ -0,0 +1,54 @@
+package main
+
+import "github.com/roffe/cim/pkg/cim"
+
+func transplantCmd(args []string) error {
+	fs := newFlagSet("transplant")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the result to file, dry run if empty")
+	groups := fs.StringSliceP("groups", "g", nil, "field groups to copy from the car: vin,pin,keys,psk,history (default all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageErrorf(fs, "transplant takes a donor and a car file")
+	}
+
+	var fg []cim.FieldGroup
+	for _, name := range *groups {
+		g, err := cim.ParseFieldGroup(name)
+		if err != nil {
+			return err
+		}
+		fg = append(fg, g)
+	}
+
+	donor, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	car, err := loadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	out, err := cim.Transplant(donor, car, fg...)
+	if err != nil {
+		return err
+	}
+	changes, err := cim.Diff(donor, out)
+	if err != nil {
+		return err
+	}
+
+	if err := printChanges(*output, changes); err != nil {
+		return err
+	}
+
+	if *write != "" {
+		return saveFile(*write, out)
+	}
+	return nil
+}
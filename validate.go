@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/roffe/cim/pkg/cim"
+)
+
+// validateCmd validates every file and exits with exitInvalid if any fails
+// validation, or exitFailure if any can't be read at all
+func validateCmd(args []string) error {
+	fs := newFlagSet("validate")
+	addInFormatFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf(fs, "validate takes one or more files")
+	}
+
+	code := 0
+	reports := make([]*cim.ValidationReport, 0, fs.NArg())
+	for _, filename := range fs.Args() {
+		fw, err := loadFile(filename)
+		if err != nil {
+			reports = append(reports, &cim.ValidationReport{Filename: filename, Problems: []error{err}})
+			code = exitFailure
+			continue
+		}
+		r := fw.Report()
+		if !r.OK() && code == 0 {
+			code = exitInvalid
+		}
+		reports = append(reports, r)
+	}
+
+	switch strings.ToLower(*output) {
+	case "json":
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		for _, r := range reports {
+			if r.OK() {
+				fmt.Printf("%s: ok\n", r.Filename)
+				continue
+			}
+			fmt.Printf("%s: %v\n", r.Filename, r)
+		}
+	}
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
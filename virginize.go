@@ -0,0 +1,45 @@
+package main
+
+import "github.com/roffe/cim/pkg/cim"
+
+func virginizeCmd(args []string) error {
+	fs := newFlagSet("virginize")
+	addInFormatFlag(fs)
+	addOutFormatFlag(fs)
+	addProfileFlag(fs)
+	output := fs.StringP("output", "o", "text", "text|json")
+	write := fs.StringP("write", "w", "", "write the virginized dump to file, dry run if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf(fs, "virginize takes exactly one file")
+	}
+
+	fw, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Virginize a working copy, fw is kept to diff against
+	e, err := fw.Edit()
+	if err != nil {
+		return err
+	}
+	out := e.Bin
+	if err := out.Virginize(); err != nil {
+		return err
+	}
+	changes, err := cim.Diff(fw, out)
+	if err != nil {
+		return err
+	}
+
+	if err := printChanges(*output, changes); err != nil {
+		return err
+	}
+
+	if *write != "" {
+		return saveFile(*write, out)
+	}
+	return nil
+}